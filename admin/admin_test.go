@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gemini-grc/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"localhost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"", false},
+		{"0.0.0.0", false},
+		{"192.168.1.10", false},
+		{"example.org", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isLoopback(tt.host))
+		})
+	}
+}
+
+func TestListenRejectsPublicAddress(t *testing.T) {
+	_, err := listen(":9091")
+	assert.Error(t, err)
+	_, err = listen("0.0.0.0:9091")
+	assert.Error(t, err)
+}
+
+// newRequest returns a request as
+// curl sends it to localhost:9091.
+func newRequest(method string, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Host = "localhost:9091"
+	if method != http.MethodGet {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+func TestRejectsBrowserRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		unixSocket bool
+		request    func() *http.Request
+		code       int
+	}{
+		{"loopback host", false, func() *http.Request {
+			return newRequest(http.MethodGet, "/status", nil)
+		}, http.StatusOK},
+		{"IPv6 loopback host", false, func() *http.Request {
+			r := newRequest(http.MethodGet, "/status", nil)
+			r.Host = "[::1]:9091"
+			return r
+		}, http.StatusOK},
+		{"other host", false, func() *http.Request {
+			r := newRequest(http.MethodGet, "/status", nil)
+			r.Host = "attacker.example:9091"
+			return r
+		}, http.StatusForbidden},
+		{"other host on unix socket", true, func() *http.Request {
+			r := newRequest(http.MethodGet, "/status", nil)
+			r.Host = "admin"
+			return r
+		}, http.StatusOK},
+		{"origin", false, func() *http.Request {
+			r := newRequest(http.MethodPost, "/pause", nil)
+			r.Header.Set("Origin", "http://localhost:9091")
+			return r
+		}, http.StatusForbidden},
+		{"form post", false, func() *http.Request {
+			r := newRequest(http.MethodPost, "/pause", nil)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return r
+		}, http.StatusUnsupportedMediaType},
+		{"no content type", false, func() *http.Request {
+			r := newRequest(http.MethodDelete, "/queue/hosts/example.org", nil)
+			r.Header.Del("Content-Type")
+			return r
+		}, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newHandler(tt.unixSocket).ServeHTTP(rec, tt.request())
+			assert.Equal(t, tt.code, rec.Code)
+		})
+	}
+}
+
+func TestWorkerEndpoints(t *testing.T) {
+	defer common.SetWorkerCount(0)
+	defer common.ResumeWorkers()
+	handler := newHandler(false)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		code     int
+		expected status
+	}{
+		{"set workers", http.MethodPost, "/workers?count=2", http.StatusOK, status{Workers: 2}},
+		{"pause", http.MethodPost, "/pause", http.StatusOK, status{Paused: true, Workers: 2}},
+		{"status", http.MethodGet, "/status", http.StatusOK, status{Paused: true, Workers: 2}},
+		{"resume", http.MethodPost, "/resume", http.StatusOK, status{Workers: 2}},
+		{"negative workers", http.MethodPost, "/workers?count=-1", http.StatusBadRequest, status{}},
+		{"no workers", http.MethodPost, "/workers?count=0", http.StatusBadRequest, status{}},
+		{"missing count", http.MethodPost, "/workers", http.StatusBadRequest, status{}},
+		{"wrong method", http.MethodGet, "/pause", http.StatusMethodNotAllowed, status{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newRequest(tt.method, tt.target, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+			var got status
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestEnqueueRejectsInvalidBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newHandler(false).ServeHTTP(rec, newRequest(http.MethodPost, "/enqueue", strings.NewReader("not json")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error"`)
+}
@@ -0,0 +1,230 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gemini-grc/common"
+	"gemini-grc/common/blackList"
+	"gemini-grc/common/takedown"
+	"gemini-grc/common/url"
+	"gemini-grc/common/whiteList"
+	"gemini-grc/contextutil"
+	gemdb "gemini-grc/db"
+	"gemini-grc/protocol"
+	"git.antanst.com/antanst/logging"
+)
+
+// newHandler puts the admin API behind checks against
+// requests a web page could make a browser send: those
+// with an Origin header, those changing something
+// without a JSON content type, which browsers only send
+// cross-origin after a CORS check we never pass, and,
+// over TCP, those for non-loopback host names, as with
+// DNS rebinding.
+func newHandler(unixSocket bool) http.Handler {
+	mux := newMux()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("requests from web pages are not allowed"))
+			return
+		}
+		if !unixSocket && !isLoopback(requestHost(r)) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %s is not a loopback address", r.Host))
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// requestHost returns the host of a
+// request's Host header, without the port.
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return strings.Trim(r.Host, "[]")
+	}
+	return host
+}
+
+// Each endpoint replies with a JSON object,
+// which has an "error" field when it fails.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", handleStatus)
+	mux.HandleFunc("POST /pause", handlePause)
+	mux.HandleFunc("POST /resume", handleResume)
+	mux.HandleFunc("POST /workers", handleWorkers)
+	mux.HandleFunc("POST /enqueue", handleEnqueue)
+	mux.HandleFunc("DELETE /queue/hosts/{host}", handleDropHost)
+	mux.HandleFunc("POST /reload", handleReload)
+	mux.HandleFunc("GET /jobs", handleJobs)
+	return mux
+}
+
+type status struct {
+	Paused  bool `json:"paused"`
+	Workers int  `json:"workers"`
+	Jobs    int  `json:"jobs"`
+}
+
+func currentStatus() status {
+	return status{
+		Paused:  common.WorkersPaused(),
+		Workers: common.WorkerCount(),
+		Jobs:    len(common.InFlightJobs()),
+	}
+}
+
+func handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, currentStatus())
+}
+
+func handlePause(w http.ResponseWriter, _ *http.Request) {
+	common.PauseWorkers()
+	writeJSON(w, http.StatusOK, currentStatus())
+}
+
+func handleResume(w http.ResponseWriter, _ *http.Request) {
+	common.ResumeWorkers()
+	writeJSON(w, http.StatusOK, currentStatus())
+}
+
+func handleWorkers(w http.ResponseWriter, r *http.Request) {
+	// As with the workers setting, at least one
+	// worker runs; use /pause to stop crawling.
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 1 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("count must be a number of workers, at least 1"))
+		return
+	}
+	common.SetWorkerCount(count)
+	writeJSON(w, http.StatusOK, currentStatus())
+}
+
+type enqueueRequest struct {
+	URLs     []string `json:"urls"`
+	Priority int      `json:"priority"`
+}
+
+type enqueueResponse struct {
+	Enqueued []string          `json:"enqueued"`
+	Rejected map[string]string `json:"rejected,omitempty"`
+}
+
+func handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req enqueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	resp := enqueueResponse{Enqueued: []string{}, Rejected: map[string]string{}}
+	var valid []string
+	for _, u := range req.URLs {
+		parsed, err := url.ParseURL(u, "", true)
+		if err != nil {
+			resp.Rejected[u] = err.Error()
+			continue
+		}
+		if !protocol.IsEnabled(parsed.Full) {
+			resp.Rejected[u] = "protocol not enabled"
+			continue
+		}
+		valid = append(valid, parsed.Full)
+	}
+
+	ctx := contextutil.ContextWithComponent(r.Context(), "admin")
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+	for _, u := range valid {
+		if err := gemdb.Database.InsertPriorityURL(ctx, tx, u, req.Priority); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp.Enqueued = append(resp.Enqueued, valid...)
+	logging.LogInfo("Admin API enqueued %d URLs with priority %d", len(valid), req.Priority)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleDropHost(w http.ResponseWriter, r *http.Request) {
+	host := r.PathValue("host")
+
+	ctx := contextutil.ContextWithComponent(r.Context(), "admin")
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+	deleted, err := gemdb.Database.DeleteHostURLs(ctx, tx, host)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	logging.LogInfo("Admin API dropped %d queued URLs of host %s", deleted, host)
+	writeJSON(w, http.StatusOK, map[string]int64{"deleted": deleted})
+}
+
+// Reloading keeps the current lists
+// if one of the files is invalid.
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := blackList.Reload(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := whiteList.Reload(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := takedown.Load(contextutil.ContextWithComponent(r.Context(), "admin")); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"reloaded": true})
+}
+
+func handleJobs(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]common.Job{"jobs": common.InFlightJobs()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
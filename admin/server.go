@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gemini-grc/config"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+)
+
+var server *http.Server //nolint:gochecknoglobals
+
+// Initialize serves the admin API, if an address is
+// configured. The API has no authentication, so it only
+// listens on loopback addresses or on a unix socket, and
+// refuses requests that browsers could be made to send.
+func Initialize() error {
+	if config.CONFIG.AdminAddr == "" {
+		return nil
+	}
+
+	listener, err := listen(config.CONFIG.AdminAddr)
+	if err != nil {
+		return err
+	}
+
+	server = &http.Server{
+		Handler:           newHandler(strings.HasPrefix(config.CONFIG.AdminAddr, "unix:")),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.LogError("Admin server failed: %v", err)
+		}
+	}()
+	logging.LogInfo("Serving admin API on %s", listener.Addr())
+	return nil
+}
+
+func Shutdown() error {
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	server = nil
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("could not stop admin server: %w", err), 0, "", false)
+	}
+	return nil
+}
+
+// listen opens a unix socket for addresses
+// like unix:/path/to.sock, otherwise a TCP
+// port that must be on a loopback address.
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, xerrors.NewError(fmt.Errorf("could not listen for admin API on %s: %w", addr, err), 0, "", true)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("invalid admin address %s: %w", addr, err), 0, "", true)
+	}
+	if !isLoopback(host) {
+		return nil, xerrors.NewError(fmt.Errorf("admin address %s is not a loopback address", addr), 0, "", true)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("could not listen for admin API on %s: %w", addr, err), 0, "", true)
+	}
+	return listener, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
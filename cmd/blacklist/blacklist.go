@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gemini-grc/common/blackList"
+	"gemini-grc/common/removal"
+	"gemini-grc/common/whiteList"
+	"gemini-grc/config"
+	"gemini-grc/contextutil"
+	gemdb "gemini-grc/db"
+	"gemini-grc/util"
+	"git.antanst.com/antanst/logging"
+	"github.com/jmoiron/sqlx"
+)
+
+// blacklist apply removes the queued URLs that the
+// blacklist matches, which the crawler would otherwise
+// only skip when it gets to them. It can also remove
+// their snapshots from the archive. Without -yes it
+// only shows what would be removed.
+
+const usage = `Usage: blacklist apply [flags]
+
+Removes queued URLs matching the blacklist, and optionally their snapshots.
+Whitelisted URLs are kept. Without -yes, only shows what would be removed.
+
+`
+
+type options struct {
+	snapshots    string // none, mark or delete
+	reason       string
+	removedBy    string
+	yes          bool
+	previewLimit int
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "apply" {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("blacklist apply", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	pgURL := flags.String("pgurl", "", "Postgres URL")
+	dbURL := flags.String("db-url", "", "Database URL, postgres://... or sqlite://path/to/file.db (overrides -pgurl)")
+	blacklistPath := flags.String("blacklist-path", "", "File that has blacklist regexes")
+	whitelistPath := flags.String("whitelist-path", "", "File with URLs that should be kept regardless of blacklist")
+	logLevel := flags.String("log-level", "info", "Logging level (debug, info, warn, error)")
+	var opts options
+	flags.StringVar(&opts.snapshots, "snapshots", "none", "What to do with snapshots of matching URLs: none, mark (hide from the archive) or delete")
+	flags.StringVar(&opts.reason, "reason", "", "Why the URLs are removed, for the audit log (required with -yes)")
+	flags.StringVar(&opts.removedBy, "by", removal.CurrentUser(), "Who removes the URLs, for the audit log")
+	flags.BoolVar(&opts.yes, "yes", false, "Remove the URLs, instead of only showing them")
+	flags.IntVar(&opts.previewLimit, "preview-limit", 20, "Maximum number of URLs to show per list (0 for all)")
+	_ = flags.Parse(os.Args[2:])
+
+	if err := validate(*blacklistPath, opts); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	level, err := config.ParseSlogLevel(*logLevel)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	logging.InitSlogger(level)
+
+	config.CONFIG.PgURL = *pgURL
+	config.CONFIG.DbURL = *dbURL
+	config.CONFIG.MaxDbConnections = 2
+	config.CONFIG.BlacklistPath = *blacklistPath
+	config.CONFIG.WhitelistPath = *whitelistPath
+
+	if err := run(opts); err != nil {
+		logging.LogError("%v", err)
+		os.Exit(1)
+	}
+}
+
+func validate(blacklistPath string, opts options) error {
+	if blacklistPath == "" {
+		return fmt.Errorf("-blacklist-path is required")
+	}
+	switch opts.snapshots {
+	case "none", gemdb.RemovalActionMark, gemdb.RemovalActionDelete:
+	default:
+		return fmt.Errorf("invalid -snapshots value: %s", opts.snapshots)
+	}
+	if opts.yes && opts.reason == "" {
+		return fmt.Errorf("-reason is required with -yes")
+	}
+	if opts.yes && opts.removedBy == "" {
+		return fmt.Errorf("-by is required with -yes")
+	}
+	return nil
+}
+
+func run(opts options) error {
+	if err := blackList.Initialize(); err != nil {
+		return err
+	}
+	if err := whiteList.Initialize(); err != nil {
+		return err
+	}
+
+	ctx := contextutil.ContextWithComponent(context.Background(), "blacklist")
+	gemdb.Database = gemdb.ForURL(config.CONFIG.DatabaseURL())
+	if err := gemdb.Database.Initialize(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.Database.Shutdown(ctx)
+	}()
+
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+
+	queued, err := matchingURLs(ctx, tx, gemdb.Database.GetQueuedURLs)
+	if err != nil {
+		return err
+	}
+
+	var archived []string
+	if opts.snapshots != "none" {
+		archived, err = matchingURLs(ctx, tx, gemdb.Database.GetSnapshotURLs)
+		if err != nil {
+			return err
+		}
+	}
+
+	printURLs("Queued URLs", queued, opts.previewLimit)
+	if opts.snapshots != "none" {
+		printURLs(fmt.Sprintf("URLs with snapshots to %s", opts.snapshots), archived, opts.previewLimit)
+	}
+	if !opts.yes {
+		fmt.Println("Nothing removed, run again with -yes to remove these")
+		return nil
+	}
+
+	by := gemdb.Removal{
+		RemovedBy: opts.removedBy,
+		Reason:    opts.reason,
+		RemovedAt: time.Now(),
+	}
+	err = removal.RemoveQueued(ctx, tx, queued, by)
+	if err != nil {
+		return err
+	}
+	var urls int
+	var snapshots int64
+	if opts.snapshots != "none" {
+		urls, snapshots, err = removal.RemoveSnapshots(ctx, tx, archived, opts.snapshots, by)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	switch opts.snapshots {
+	case gemdb.RemovalActionMark:
+		fmt.Printf("Removed %d queued URLs, marked %d snapshots of %d URLs as removed\n", len(queued), snapshots, urls)
+	case gemdb.RemovalActionDelete:
+		fmt.Printf("Removed %d queued URLs, deleted %d snapshots of %d URLs\n", len(queued), snapshots, urls)
+	default:
+		fmt.Printf("Removed %d queued URLs\n", len(queued))
+	}
+	return nil
+}
+
+// matchingURLs returns the URLs that list finds and the
+// blacklist matches. If every blacklist pattern has a
+// literal prefix, list only reads the URLs starting
+// with one, otherwise it reads every URL.
+func matchingURLs(ctx context.Context, tx *sqlx.Tx, list func(context.Context, *sqlx.Tx, gemdb.URLFilter) ([]string, error)) ([]string, error) {
+	prefixes, ok := blackList.Prefixes()
+	if !ok {
+		prefixes = []string{""}
+	}
+	var urls []string
+	for _, prefix := range outermostPrefixes(prefixes) {
+		found, err := list(ctx, tx, gemdb.URLFilter{Prefix: prefix})
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, util.Filter(found, matches)...)
+	}
+	slices.Sort(urls)
+	return urls, nil
+}
+
+// outermostPrefixes drops the prefixes that start with
+// another one, so that no URL is read twice.
+func outermostPrefixes(prefixes []string) []string {
+	sorted := slices.Clone(prefixes)
+	slices.Sort(sorted)
+	var outermost []string
+	for _, prefix := range sorted {
+		if len(outermost) > 0 && strings.HasPrefix(prefix, outermost[len(outermost)-1]) {
+			continue
+		}
+		outermost = append(outermost, prefix)
+	}
+	return outermost
+}
+
+// matches reports if the crawler would skip a URL.
+func matches(url string) bool {
+	return blackList.IsBlacklisted(url) && !whiteList.IsWhitelisted(url)
+}
+
+func printURLs(title string, urls []string, limit int) {
+	fmt.Printf("%s: %d\n", title, len(urls))
+	for i, url := range urls {
+		if limit > 0 && i == limit {
+			fmt.Printf("  ... and %d more\n", len(urls)-limit)
+			break
+		}
+		fmt.Printf("  %s\n", url)
+	}
+}
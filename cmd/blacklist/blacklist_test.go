@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		blacklistPath string
+		opts          options
+		wantErr       bool
+	}{
+		{"preview", "blacklist.txt", options{snapshots: "none"}, false},
+		{"no blacklist", "", options{snapshots: "none"}, true},
+		{"invalid snapshots action", "blacklist.txt", options{snapshots: "purge"}, true},
+		{"apply without reason", "blacklist.txt", options{snapshots: "none", removedBy: "admin", yes: true}, true},
+		{"apply without user", "blacklist.txt", options{snapshots: "mark", reason: "spam", yes: true}, true},
+		{"apply", "blacklist.txt", options{snapshots: "delete", reason: "takedown request", removedBy: "admin", yes: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.blacklistPath, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutermostPrefixes(t *testing.T) {
+	got := outermostPrefixes([]string{"gemini://b/", "gemini://a/x", "gemini://a/", "gemini://b/"})
+	want := []string{"gemini://a/", "gemini://b/"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("outermostPrefixes() = %q, want %q", got, want)
+	}
+	got = outermostPrefixes([]string{"gemini://a/", ""})
+	if len(got) != 1 || got[0] != "" {
+		t.Errorf("outermostPrefixes() = %q, want [\"\"]", got)
+	}
+}
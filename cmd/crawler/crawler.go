@@ -5,17 +5,23 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"gemini-grc/admin"
 	"gemini-grc/common"
 	"gemini-grc/common/blackList"
 	"gemini-grc/common/contextlog"
+	"gemini-grc/common/hostBudget"
+	"gemini-grc/common/inputQueries"
 	"gemini-grc/common/seedList"
+	"gemini-grc/common/takedown"
 	"gemini-grc/common/whiteList"
 	"gemini-grc/config"
 	"gemini-grc/contextutil"
 	gemdb "gemini-grc/db"
+	"gemini-grc/metrics"
 	"gemini-grc/robotsMatch"
 	"gemini-grc/util"
 	"git.antanst.com/antanst/logging"
@@ -24,6 +30,11 @@ import (
 
 var jobs chan string
 
+var reloadSignals chan os.Signal //nolint:gochecknoglobals
+
+// crawlDone is closed when a dry run has crawled enough.
+var crawlDone chan struct{} //nolint:gochecknoglobals
+
 func main() {
 	var err error
 
@@ -56,8 +67,11 @@ func initializeApp() error {
 	logging.LogInfo("Starting up. Press Ctrl+C to exit")
 	common.SignalsChan = make(chan os.Signal, 1)
 	signal.Notify(common.SignalsChan, syscall.SIGINT, syscall.SIGTERM)
+	reloadSignals = make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
 	common.FatalErrorsChan = make(chan error)
 	jobs = make(chan string, config.CONFIG.NumOfWorkers)
+	crawlDone = make(chan struct{})
 
 	var err error
 
@@ -76,16 +90,46 @@ func initializeApp() error {
 		return err
 	}
 
+	err = inputQueries.Initialize()
+	if err != nil {
+		return err
+	}
+
+	err = metrics.Initialize()
+	if err != nil {
+		return err
+	}
+
+	err = takedown.Initialize()
+	if err != nil {
+		return err
+	}
+
 	err = robotsMatch.Initialize()
 	if err != nil {
 		return err
 	}
 
+	gemdb.Database = gemdb.ForURL(config.CONFIG.DatabaseURL())
+	if config.CONFIG.DryRun {
+		gemdb.Database = gemdb.NewMemoryDbService(os.Stdout)
+	}
 	ctx := context.Background()
 	err = gemdb.Database.Initialize(ctx)
 	if err != nil {
 		return err
 	}
+	robotsMatch.EnablePersistence()
+
+	err = takedown.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = admin.Initialize()
+	if err != nil {
+		return err
+	}
 
 	if config.CONFIG.SeedUrlPath != "" {
 		err := AddURLsFromFile(ctx, config.CONFIG.SeedUrlPath)
@@ -100,6 +144,11 @@ func initializeApp() error {
 func shutdownApp() error {
 	var err error
 
+	err = admin.Shutdown()
+	if err != nil {
+		return err
+	}
+
 	err = blackList.Shutdown()
 	if err != nil {
 		return err
@@ -115,6 +164,21 @@ func shutdownApp() error {
 		return err
 	}
 
+	err = inputQueries.Shutdown()
+	if err != nil {
+		return err
+	}
+
+	err = metrics.Shutdown()
+	if err != nil {
+		return err
+	}
+
+	err = takedown.Shutdown()
+	if err != nil {
+		return err
+	}
+
 	err = robotsMatch.Shutdown()
 	if err != nil {
 		return err
@@ -130,27 +194,55 @@ func shutdownApp() error {
 }
 
 func runApp() (err error) {
-	go spawnWorkers(config.CONFIG.NumOfWorkers)
+	common.StartWorkers(jobs, config.CONFIG.NumOfWorkers)
 	go runJobScheduler()
+
+	// Background refreshers stop before
+	// shutdownApp closes the database.
+	refreshCtx, stopRefreshers := context.WithCancel(context.Background())
+	var refreshers sync.WaitGroup
+	defer func() {
+		stopRefreshers()
+		refreshers.Wait()
+	}()
+	refreshers.Add(2)
+	go func() {
+		defer refreshers.Done()
+		robotsMatch.RunCacheRefresher(refreshCtx)
+	}()
+	go func() {
+		defer refreshers.Done()
+		takedown.RunRefresher(refreshCtx)
+	}()
+
 	for {
 		select {
 		case <-common.SignalsChan:
 			logging.LogWarn("Received SIGINT or SIGTERM signal, exiting")
 			return nil
+		case <-reloadSignals:
+			logging.LogInfo("Received SIGHUP signal, reloading blacklist, whitelist and takedown rules")
+			reloadLists()
+		case <-crawlDone:
+			logging.LogInfo("Dry run done, exiting")
+			return nil
 		case err := <-common.FatalErrorsChan:
 			return err
 		}
 	}
 }
 
-func spawnWorkers(total int) {
-	for id := 0; id < total; id++ {
-		go func(a int) {
-			for {
-				job := <-jobs
-				common.RunWorkerWithTx(a, job)
-			}
-		}(id)
+// reloadLists keeps the current lists
+// if their files have become invalid.
+func reloadLists() {
+	if err := blackList.Reload(); err != nil {
+		logging.LogError("Keeping current blacklist: %v", err)
+	}
+	if err := whiteList.Reload(); err != nil {
+		logging.LogError("Keeping current whitelist: %v", err)
+	}
+	if err := takedown.Load(context.Background()); err != nil {
+		logging.LogError("Keeping current takedown rules: %v", err)
 	}
 }
 
@@ -163,6 +255,9 @@ func spawnWorkers(total int) {
 // 5. Get URLs from hosts
 // 6. Commit transaction
 // 7. Queue URLs for workers
+//
+// A dry run instead stops once the queue is empty
+// or it has crawled config.CONFIG.DryRunLimit URLs.
 func runJobScheduler() {
 	var tx *sqlx.Tx
 	var err error
@@ -183,13 +278,7 @@ func runJobScheduler() {
 	}(tx)
 
 	// First, check if the URLs table is empty.
-	var urlCount int
-
-	if config.CONFIG.GopherEnable {
-		err = tx.Get(&urlCount, "SELECT COUNT(*) FROM urls")
-	} else {
-		err = tx.Get(&urlCount, "SELECT COUNT(*) FROM urls WHERE url LIKE 'gemini://%'")
-	}
+	urlCount, err := gemdb.Database.CountURLs(ctx, tx)
 	if err != nil {
 		common.FatalErrorsChan <- err
 		return
@@ -202,14 +291,13 @@ func runJobScheduler() {
 	}
 
 	// If no pending URLs, add the ones from the standard crawl set.
-	tx, err = gemdb.Database.NewTx(ctx)
-	if err != nil {
-		common.FatalErrorsChan <- err
-		return
-	}
-
 	if urlCount == 0 {
 		logging.LogInfo("URLs table is empty, enqueueing standard crawl set")
+		tx, err = gemdb.Database.NewTx(ctx)
+		if err != nil {
+			common.FatalErrorsChan <- err
+			return
+		}
 		err = enqueueSeedURLs(ctx, tx)
 		if err != nil {
 			common.FatalErrorsChan <- err
@@ -229,7 +317,14 @@ func runJobScheduler() {
 	// We get URLs from the pending URLs table,
 	// add crawling jobs for those,
 	// and sleep a bit after each run.
+	crawled := 0
 	for {
+		if config.CONFIG.DryRun && config.CONFIG.DryRunLimit > 0 && crawled >= config.CONFIG.DryRunLimit {
+			contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Crawled %d URLs, the dry run limit", crawled)
+			close(crawlDone)
+			return
+		}
+
 		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Polling DB for jobs")
 
 		// Use fresh context for DB operations to avoid timeouts/cancellation
@@ -248,15 +343,30 @@ func runJobScheduler() {
 			return
 		}
 
+		if config.CONFIG.MetricsAddr != "" {
+			queueSize, err := gemdb.Database.CountURLs(dbCtx, tx)
+			if err != nil {
+				common.FatalErrorsChan <- err
+				return
+			}
+			metrics.QueueSize.Set(float64(queueSize))
+		}
+
 		// When out of pending URLs, add some random ones.
-		if len(distinctHosts) == 0 {
+		if len(distinctHosts) == 0 && !config.CONFIG.DryRun {
 			// Queue random old URLs from history.
-			count, err := fetchSnapshotsFromHistory(dbCtx, tx, config.CONFIG.NumOfWorkers, config.CONFIG.SkipIfUpdatedDays)
+			count, err := fetchSnapshotsFromHistory(dbCtx, tx, common.WorkerCount(), config.CONFIG.SkipIfUpdatedDays)
 			if err != nil {
 				common.FatalErrorsChan <- err
 				return
 			}
 			if count == 0 {
+				// Don't hold the transaction while sleeping
+				err = tx.Commit()
+				if err != nil {
+					common.FatalErrorsChan <- err
+					return
+				}
 				contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "No work, waiting to poll DB...")
 				time.Sleep(120 * time.Second)
 				continue
@@ -269,7 +379,11 @@ func runJobScheduler() {
 		}
 
 		// Get some URLs from each host, up to a limit
-		urls, err := gemdb.Database.GetRandomUrlsFromHosts(dbCtx, distinctHosts, config.CONFIG.NumOfWorkers, tx)
+		hostLimits := make(map[string]int, len(distinctHosts))
+		for _, host := range distinctHosts {
+			hostLimits[host] = hostBudget.For(host).CycleLimit(common.WorkerCount())
+		}
+		urls, err := gemdb.Database.GetRandomUrlsFromHosts(dbCtx, hostLimits, tx)
 		if err != nil {
 			common.FatalErrorsChan <- err
 			return
@@ -281,6 +395,17 @@ func runJobScheduler() {
 			return
 		}
 
+		if config.CONFIG.DryRun && config.CONFIG.DryRunLimit > 0 && len(urls) > config.CONFIG.DryRunLimit-crawled {
+			urls = urls[:config.CONFIG.DryRunLimit-crawled]
+		}
+		crawled += len(urls)
+
+		if len(urls) == 0 && config.CONFIG.DryRun {
+			contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Crawled %d URLs, none left in the queue", crawled)
+			close(crawlDone)
+			return
+		}
+
 		if len(urls) == 0 {
 			contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "No work, waiting to poll DB...")
 			time.Sleep(120 * time.Second)
@@ -328,30 +453,21 @@ func fetchSnapshotsFromHistory(ctx context.Context, tx *sqlx.Tx, num int, age in
 	// Calculate the cutoff date
 	cutoffDate := time.Now().AddDate(0, 0, -age)
 
-	// Use the query from db_queries.go to find URLs that need re-crawling
-
-	type SnapshotURL struct {
-		URL  string `db:"url"`
-		Host string `db:"host"`
-	}
-
-	// Execute the query
-	var snapshotURLs []SnapshotURL
-	err := tx.Select(&snapshotURLs, gemdb.SQL_FETCH_SNAPSHOTS_FROM_HISTORY, cutoffDate, num)
+	urls, err := gemdb.Database.GetURLsToRecrawl(ctx, tx, cutoffDate, num)
 	if err != nil {
 		return 0, err
 	}
 
-	if len(snapshotURLs) == 0 {
+	if len(urls) == 0 {
 		return 0, nil
 	}
 
 	// For each selected snapshot, add the URL to the urls table
 	insertCount := 0
-	for _, snapshot := range snapshotURLs {
-		err := gemdb.Database.InsertURL(ctx, tx, snapshot.URL)
+	for _, url := range urls {
+		err := gemdb.Database.InsertURL(ctx, tx, url)
 		if err != nil {
-			logging.LogError("Error inserting URL %s from old snapshot to queue: %v", snapshot.URL, err)
+			logging.LogError("Error inserting URL %s from old snapshot to queue: %v", url, err)
 			return 0, err
 		}
 		insertCount++
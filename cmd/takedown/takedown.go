@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gemini-grc/common/removal"
+	"gemini-grc/common/takedown"
+	"gemini-grc/config"
+	"gemini-grc/contextutil"
+	gemdb "gemini-grc/db"
+	"git.antanst.com/antanst/logging"
+	"github.com/jmoiron/sqlx"
+)
+
+// takedown manages the rules of URLs whose authors asked
+// us not to crawl or archive them. Adding a rule also
+// removes matching queued URLs and tombstones matching
+// snapshots; a running crawler picks up rule changes
+// within a minute, or on SIGHUP.
+
+const usage = `Usage:
+  takedown add -kind host|prefix|regex -pattern PATTERN -reason REASON [flags]
+  takedown list [-all] [flags]
+  takedown revoke -id ID [flags]
+
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("takedown "+os.Args[1], flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	pgURL := flags.String("pgurl", "", "Postgres URL")
+	dbURL := flags.String("db-url", "", "Database URL, postgres://... or sqlite://path/to/file.db (overrides -pgurl)")
+	logLevel := flags.String("log-level", "info", "Logging level (debug, info, warn, error)")
+
+	var run func(ctx context.Context) error
+	switch os.Args[1] {
+	case "add":
+		kind := flags.String("kind", "", "What the pattern is: host, prefix (a URL prefix) or regex")
+		pattern := flags.String("pattern", "", "The hostname, URL prefix or regex to take down")
+		reason := flags.String("reason", "", "Why, e.g. who asked and when")
+		createdBy := flags.String("by", removal.CurrentUser(), "Who adds the rule")
+		run = func(ctx context.Context) error {
+			return add(ctx, *kind, *pattern, *reason, *createdBy)
+		}
+	case "list":
+		all := flags.Bool("all", false, "Include revoked rules")
+		run = func(ctx context.Context) error {
+			return list(ctx, *all)
+		}
+	case "revoke":
+		id := flags.Int("id", 0, "ID of the rule to revoke")
+		run = func(ctx context.Context) error {
+			return revoke(ctx, *id)
+		}
+	default:
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	_ = flags.Parse(os.Args[2:])
+
+	level, err := config.ParseSlogLevel(*logLevel)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	logging.InitSlogger(level)
+
+	config.CONFIG.PgURL = *pgURL
+	config.CONFIG.DbURL = *dbURL
+	config.CONFIG.MaxDbConnections = 2
+
+	ctx := contextutil.ContextWithComponent(context.Background(), "takedown")
+	gemdb.Database = gemdb.ForURL(config.CONFIG.DatabaseURL())
+	if err := gemdb.Database.Initialize(ctx); err != nil {
+		logging.LogError("%v", err)
+		os.Exit(1)
+	}
+	err = run(ctx)
+	_ = gemdb.Database.Shutdown(ctx)
+	if err != nil {
+		logging.LogError("%v", err)
+		os.Exit(1)
+	}
+}
+
+// newRule validates the flags of takedown add.
+func newRule(kind string, pattern string, reason string, createdBy string) (*gemdb.TakedownRule, error) {
+	pattern, err := takedown.NormalizePattern(kind, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("-reason is required")
+	}
+	if createdBy == "" {
+		return nil, fmt.Errorf("-by is required")
+	}
+	return &gemdb.TakedownRule{
+		Kind:      kind,
+		Pattern:   pattern,
+		Reason:    reason,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func add(ctx context.Context, kind string, pattern string, reason string, createdBy string) error {
+	rule, err := newRule(kind, pattern, reason, createdBy)
+	if err != nil {
+		return err
+	}
+
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+
+	rule.ID, err = gemdb.Database.AddTakedownRule(ctx, tx, rule)
+	if err != nil {
+		return err
+	}
+	queued, err := matchingURLs(ctx, tx, rule, gemdb.Database.GetQueuedURLs)
+	if err != nil {
+		return err
+	}
+	archived, err := matchingURLs(ctx, tx, rule, gemdb.Database.GetSnapshotURLs)
+	if err != nil {
+		return err
+	}
+	by := gemdb.Removal{
+		RemovedBy: rule.CreatedBy,
+		Reason:    fmt.Sprintf("takedown rule %d: %s", rule.ID, rule.Reason),
+		RemovedAt: rule.CreatedAt,
+	}
+	err = removal.RemoveQueued(ctx, tx, queued, by)
+	if err != nil {
+		return err
+	}
+	urls, snapshots, err := removal.RemoveSnapshots(ctx, tx, archived, gemdb.RemovalActionTombstone, by)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added takedown rule %d: %s %s\n", rule.ID, rule.Kind, rule.Pattern)
+	fmt.Printf("Removed %d queued URLs, tombstoned %d snapshots of %d URLs\n", len(queued), snapshots, urls)
+	return nil
+}
+
+// matchingURLs returns the URLs that list finds and
+// the rule matches. Host and prefix rules are matched
+// in SQL, and every rule is matched in Go.
+func matchingURLs(ctx context.Context, tx *sqlx.Tx, rule *gemdb.TakedownRule, list func(context.Context, *sqlx.Tx, gemdb.URLFilter) ([]string, error)) ([]string, error) {
+	urls, err := list(ctx, tx, takedown.Filter(*rule))
+	if err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, url := range urls {
+		matches, err := takedown.Matches(*rule, url)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			matching = append(matching, url)
+		}
+	}
+	return matching, nil
+}
+
+func list(ctx context.Context, all bool) error {
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+
+	rules, err := gemdb.Database.GetTakedownRules(ctx, tx, all)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tKIND\tPATTERN\tCREATED\tBY\tREVOKED\tREASON")
+	for _, r := range rules {
+		revoked := "-"
+		if r.RevokedAt.Valid {
+			revoked = r.RevokedAt.Time.Format(time.DateOnly)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			strconv.Itoa(r.ID), r.Kind, r.Pattern, r.CreatedAt.Format(time.DateOnly), r.CreatedBy, revoked, r.Reason)
+	}
+	return w.Flush()
+}
+
+// Revoking a rule lets the crawler visit its URLs
+// again. Tombstoned snapshots stay tombstoned.
+func revoke(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+
+	revoked, err := gemdb.Database.RevokeTakedownRule(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return fmt.Errorf("no active takedown rule with ID %d", id)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Revoked takedown rule %d\n", id)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		pattern   string
+		reason    string
+		createdBy string
+		expected  string
+		wantErr   bool
+	}{
+		{"host", "host", "Example.org", "author request by email", "admin", "example.org", false},
+		{"prefix", "prefix", "gemini://example.org/~bob/", "author request", "admin", "gemini://example.org:1965/~bob/", false},
+		{"no reason", "host", "example.org", "", "admin", "", true},
+		{"no user", "host", "example.org", "author request", "", "", true},
+		{"no kind", "", "example.org", "author request", "admin", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := newRule(tt.kind, tt.pattern, tt.reason, tt.createdBy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && rule.Pattern != tt.expected {
+				t.Errorf("newRule() pattern = %q, want %q", rule.Pattern, tt.expected)
+			}
+		})
+	}
+}
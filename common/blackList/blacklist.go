@@ -4,14 +4,23 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"regexp/syntax"
 	"strings"
+	"sync"
+	"time"
 
+	"gemini-grc/common/fileWatch"
 	"gemini-grc/config"
+	"gemini-grc/util"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
 )
 
-var blacklist []regexp.Regexp //nolint:gochecknoglobals
+var (
+	blacklist    []regexp.Regexp //nolint:gochecknoglobals
+	blacklistMu  sync.RWMutex    //nolint:gochecknoglobals
+	stopWatching func()          //nolint:gochecknoglobals
+)
 
 func Initialize() error {
 	var err error
@@ -21,24 +30,81 @@ func Initialize() error {
 		if err = loadBlacklist(config.CONFIG.BlacklistPath); err != nil {
 			return err
 		}
+		if config.CONFIG.ListsWatchInterval > 0 {
+			interval := time.Duration(config.CONFIG.ListsWatchInterval) * time.Second
+			stopWatching = fileWatch.Watch(config.CONFIG.BlacklistPath, interval, func() {
+				if err := Reload(); err != nil {
+					logging.LogError("Keeping current blacklist: %v", err)
+				}
+			})
+		}
 	}
 
 	return nil
 }
 
 func loadBlacklist(filePath string) error {
-	if blacklist != nil {
+	entries, err := readBlacklist(filePath)
+	if err != nil {
+		setBlacklist([]regexp.Regexp{})
+		return err
+	}
+	setBlacklist(entries)
+
+	if len(entries) > 0 {
+		logging.LogInfo("Loaded %d blacklist entries", len(entries))
+	}
+
+	return nil
+}
+
+// Reload reads the blacklist file again, and logs
+// which entries were added or removed. If the file
+// can't be read or has invalid entries, the current
+// blacklist is kept.
+func Reload() error {
+	if config.CONFIG.BlacklistPath == "" {
 		return nil
 	}
+	entries, err := readBlacklist(config.CONFIG.BlacklistPath)
+	if err != nil {
+		return err
+	}
+	previous := setBlacklist(entries)
 
+	added, removed := util.Diff(patterns(previous), patterns(entries))
+	if len(added) == 0 && len(removed) == 0 {
+		logging.LogInfo("Reloaded blacklist, %d entries unchanged", len(entries))
+		return nil
+	}
+	logging.LogInfo("Reloaded blacklist, %d entries, added %q, removed %q", len(entries), added, removed)
+	return nil
+}
+
+// setBlacklist replaces the blacklist,
+// returning the previous one.
+func setBlacklist(entries []regexp.Regexp) []regexp.Regexp {
+	blacklistMu.Lock()
+	defer blacklistMu.Unlock()
+	previous := blacklist
+	blacklist = entries
+	return previous
+}
+
+func patterns(entries []regexp.Regexp) []string {
+	return util.Map(entries, func(r regexp.Regexp) string {
+		return r.String()
+	})
+}
+
+func readBlacklist(filePath string) ([]regexp.Regexp, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		blacklist = []regexp.Regexp{}
-		return xerrors.NewError(fmt.Errorf("could not load blacklist file: %w", err), 0, "", true)
+		return nil, xerrors.NewError(fmt.Errorf("could not load blacklist file: %w", err), 0, "", true)
 	}
 
 	lines := strings.Split(string(data), "\n")
-	blacklist = []regexp.Regexp{}
+	entries := []regexp.Regexp{}
 
 	for _, line := range lines {
 		if line == "" || strings.HasPrefix(line, "#") {
@@ -46,24 +112,62 @@ func loadBlacklist(filePath string) error {
 		}
 		regex, err := regexp.Compile(line)
 		if err != nil {
-			return xerrors.NewError(fmt.Errorf("could not compile blacklist line %s: %w", line, err), 0, "", true)
+			return nil, xerrors.NewError(fmt.Errorf("could not compile blacklist line %s: %w", line, err), 0, "", true)
 		}
-		blacklist = append(blacklist, *regex)
-	}
-
-	if len(blacklist) > 0 {
-		logging.LogInfo("Loaded %d blacklist entries", len(blacklist))
+		entries = append(entries, *regex)
 	}
 
-	return nil
+	return entries, nil
 }
 
 func Shutdown() error {
+	if stopWatching != nil {
+		stopWatching()
+		stopWatching = nil
+	}
 	return nil
 }
 
+// Prefixes returns the literal prefixes of the blacklist
+// patterns that are anchored with ^, such as `gemini://host/`
+// of `^gemini://host/.*`. URLs without one of these
+// prefixes can't match. If a pattern has no such prefix
+// and can match anywhere, ok is false.
+func Prefixes() (prefixes []string, ok bool) {
+	blacklistMu.RLock()
+	defer blacklistMu.RUnlock()
+	for i := range blacklist {
+		prefix, found := anchoredPrefix(&blacklist[i])
+		if !found {
+			return nil, false
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, true
+}
+
+// anchoredPrefix returns the literal text that every
+// match of a regex starts with, if it's anchored.
+func anchoredPrefix(r *regexp.Regexp) (string, bool) {
+	re, err := syntax.Parse(r.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+	literal := re.Sub[1]
+	if literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+	return string(literal.Rune), true
+}
+
 // IsBlacklisted checks if the URL matches any blacklist pattern
 func IsBlacklisted(u string) bool {
+	blacklistMu.RLock()
+	defer blacklistMu.RUnlock()
 	for _, v := range blacklist {
 		if v.MatchString(u) {
 			return true
@@ -527,3 +527,74 @@ malicious\.org
 		}
 	}
 }
+
+func TestReloadKeepsBlacklistOnError(t *testing.T) {
+	originalBlacklist := blacklist
+	originalBlacklistPath := config.CONFIG.BlacklistPath
+	defer func() {
+		blacklist = originalBlacklist
+		config.CONFIG.BlacklistPath = originalBlacklistPath
+	}()
+
+	tmpFile, err := os.CreateTemp("", "blacklist-reload-*.txt")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	config.CONFIG.BlacklistPath = tmpFile.Name()
+	if err := os.WriteFile(tmpFile.Name(), []byte("example\\.com\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write to temporary file: %v", err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if !IsBlacklisted("gemini://example.com/") {
+		t.Errorf("Expected URL to be blacklisted after reload")
+	}
+
+	if err := os.WriteFile(tmpFile.Name(), []byte("other\\.org\n[invalid\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write to temporary file: %v", err)
+	}
+	if err := Reload(); err == nil {
+		t.Errorf("Expected Reload() to fail on an invalid regex")
+	}
+	if !IsBlacklisted("gemini://example.com/") || IsBlacklisted("gemini://other.org/") {
+		t.Errorf("Expected the previous blacklist to be kept")
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	originalBlacklist := blacklist
+	defer func() {
+		blacklist = originalBlacklist
+	}()
+
+	tests := []struct {
+		name     string
+		patterns []string
+		prefixes []string
+		ok       bool
+	}{
+		{"empty blacklist", nil, nil, true},
+		{"anchored", []string{`^gemini://example\.com/.*`, `^gemini://spam\.org`}, []string{"gemini://example.com/", "gemini://spam.org"}, true},
+		{"prefix before a repeat", []string{`^gemini://a+`}, []string{"gemini://"}, true},
+		{"unanchored", []string{`^gemini://example\.com/`, `example\.org`}, nil, false},
+		{"alternation", []string{`^gemini://a|^gemini://b`}, nil, false},
+		{"top level alternation", []string{`^gemini://a|b`}, nil, false},
+		{"case insensitive", []string{`(?i)^gemini://example`}, nil, false},
+		{"multi-line", []string{`(?m)^gemini://example`}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blacklist = []regexp.Regexp{}
+			for _, p := range tt.patterns {
+				blacklist = append(blacklist, *regexp.MustCompile(p))
+			}
+			prefixes, ok := Prefixes()
+			if ok != tt.ok || strings.Join(prefixes, " ") != strings.Join(tt.prefixes, " ") {
+				t.Errorf("Prefixes() = %q, %v, want %q, %v", prefixes, ok, tt.prefixes, tt.ok)
+			}
+		})
+	}
+}
@@ -5,4 +5,5 @@ import "fmt"
 var (
 	ErrBlacklistMatch = fmt.Errorf("black list match")
 	ErrRobotsMatch    = fmt.Errorf("robots match")
+	ErrRedirectLoop   = fmt.Errorf("redirect loop")
 )
@@ -0,0 +1,40 @@
+package fileWatch
+
+import (
+	"os"
+	"time"
+)
+
+// Watch checks a file every interval and calls
+// onChange when its modification time or size
+// changed, until the returned function is called.
+// A file that can't be read, e.g. while an editor
+// replaces it, is checked again next time.
+func Watch(path string, interval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	last, _ := os.Stat(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				onChange()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
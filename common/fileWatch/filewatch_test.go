@@ -0,0 +1,43 @@
+package fileWatch
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "watch-*.txt")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	changes := make(chan struct{}, 10)
+	stop := Watch(tmpFile.Name(), 5*time.Millisecond, func() {
+		changes <- struct{}{}
+	})
+	defer stop()
+
+	select {
+	case <-changes:
+		t.Fatalf("Unchanged file reported as changed")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(tmpFile.Name(), later, later); err != nil {
+		t.Fatalf("Failed to change file times: %v", err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("Changed file not reported")
+	}
+
+	select {
+	case <-changes:
+		t.Errorf("Change reported twice")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
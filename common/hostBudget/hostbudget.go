@@ -0,0 +1,47 @@
+package hostBudget
+
+import "gemini-grc/config"
+
+// Budget limits how much of a host we crawl.
+// Zero means no limit.
+type Budget struct {
+	URLsPerCycle int // URLs the scheduler picks per run
+	MaxURLs      int // URLs queued or archived
+	MaxDepth     int // Link depth from a seed or capsule root
+}
+
+// For returns the budget of a host, from the
+// flags or the host's section of the config file.
+func For(host string) Budget {
+	settings := config.CONFIG.ForHost(host)
+	return Budget{
+		URLsPerCycle: settings.URLsPerCycle,
+		MaxURLs:      settings.MaxURLs,
+		MaxDepth:     settings.MaxDepth,
+	}
+}
+
+// CycleLimit returns how many URLs of the host the
+// scheduler picks per run, at most limit.
+func (b Budget) CycleLimit(limit int) int {
+	if b.URLsPerCycle > 0 && b.URLsPerCycle < limit {
+		return b.URLsPerCycle
+	}
+	return limit
+}
+
+// AllowsDepth reports if a URL at the given
+// link depth is within the budget.
+func (b Budget) AllowsDepth(depth int) bool {
+	return b.MaxDepth == 0 || depth <= b.MaxDepth
+}
+
+// LinkDepth returns the depth of a link found
+// on a page of the given depth. Capsule roots
+// start over at zero, like seeds do.
+func LinkDepth(parentDepth int, linkPath string) int {
+	if linkPath == "" || linkPath == "/" {
+		return 0
+	}
+	return parentDepth + 1
+}
@@ -0,0 +1,91 @@
+package hostBudget
+
+import (
+	"testing"
+
+	"gemini-grc/config"
+)
+
+func TestFor(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.HostURLsPerCycle = 5
+	config.CONFIG.HostMaxURLs = 1000
+	config.CONFIG.MaxDepth = 10
+	perCycle, maxURLs, noMaxDepth := 2, 10000, 0
+	config.CONFIG.Hosts = []config.HostConfig{
+		{Host: "gemini.example.com", HostURLsPerCycle: &perCycle, HostMaxURLs: &maxURLs},
+		{Host: "*.example.org", MaxDepth: &noMaxDepth},
+	}
+
+	tests := []struct {
+		host string
+		want Budget
+	}{
+		{"gemini.example.com", Budget{URLsPerCycle: 2, MaxURLs: 10000, MaxDepth: 10}},
+		{"Gopher.Example.org", Budget{URLsPerCycle: 5, MaxURLs: 1000, MaxDepth: 0}},
+		{"other.example.com", Budget{URLsPerCycle: 5, MaxURLs: 1000, MaxDepth: 10}},
+	}
+	for _, tt := range tests {
+		if got := For(tt.host); got != tt.want {
+			t.Errorf("For(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestLinkDepth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		parent int
+		path   string
+		want   int
+	}{
+		{0, "/docs/", 1},
+		{3, "/docs/page.gmi", 4},
+		{3, "/", 0},
+		{3, "", 0},
+	}
+	for _, tt := range tests {
+		if got := LinkDepth(tt.parent, tt.path); got != tt.want {
+			t.Errorf("LinkDepth(%d, %q) = %d, want %d", tt.parent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCycleLimit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		budget Budget
+		limit  int
+		want   int
+	}{
+		{Budget{}, 10, 10},
+		{Budget{URLsPerCycle: 3}, 10, 3},
+		{Budget{URLsPerCycle: 20}, 10, 10},
+	}
+	for _, tt := range tests {
+		if got := tt.budget.CycleLimit(tt.limit); got != tt.want {
+			t.Errorf("%+v.CycleLimit(%d) = %d, want %d", tt.budget, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsDepth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		budget Budget
+		depth  int
+		want   bool
+	}{
+		{Budget{}, 100, true},
+		{Budget{MaxDepth: 2}, 2, true},
+		{Budget{MaxDepth: 2}, 3, false},
+	}
+	for _, tt := range tests {
+		if got := tt.budget.AllowsDepth(tt.depth); got != tt.want {
+			t.Errorf("%+v.AllowsDepth(%d) = %v, want %v", tt.budget, tt.depth, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package inputQueries
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/config"
+	"gemini-grc/gemini"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+)
+
+// Canned queries per Gemini input endpoint, keyed
+// by the normalized endpoint URL without a query.
+var inputQueries map[string][]string //nolint:gochecknoglobals
+
+func Initialize() error {
+	if config.CONFIG.InputQueriesPath != "" {
+		if err := loadInputQueries(config.CONFIG.InputQueriesPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInputQueries reads a file with lines of
+// an endpoint URL, whitespace and a query:
+//
+//	gemini://kennedy.gemi.dev/search smolnet history
+//
+// Endpoints can be listed more than once.
+func loadInputQueries(filePath string) error {
+	if inputQueries != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		inputQueries = map[string][]string{}
+		return xerrors.NewError(fmt.Errorf("could not load input queries file: %w", err), 0, "", true)
+	}
+
+	inputQueries = map[string][]string{}
+	count := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		endpoint, query, found := strings.Cut(line, " ")
+		query = strings.TrimSpace(query)
+		if !found || query == "" {
+			return xerrors.NewError(fmt.Errorf("input queries line without a query: %s", line), 0, "", true)
+		}
+		key, err := endpointKey(endpoint)
+		if err != nil {
+			return xerrors.NewError(fmt.Errorf("could not parse input queries line %s: %w", line, err), 0, "", true)
+		}
+		inputQueries[key] = append(inputQueries[key], query)
+		count++
+	}
+
+	if count > 0 {
+		logging.LogInfo("Loaded %d input queries", count)
+	}
+
+	return nil
+}
+
+func Shutdown() error {
+	return nil
+}
+
+// QueriesFor returns the canned queries of
+// an input endpoint, if it's allowlisted.
+func QueriesFor(endpoint string) []string {
+	key, err := endpointKey(endpoint)
+	if err != nil {
+		return nil
+	}
+	return inputQueries[key]
+}
+
+// endpointKey normalizes an endpoint the way
+// the crawler does, so that lookups match.
+func endpointKey(endpoint string) (string, error) {
+	u, err := commonUrl.ParseURL(endpoint, "", true)
+	if err != nil {
+		return "", err
+	}
+	return gemini.InputEndpoint(u.Full), nil
+}
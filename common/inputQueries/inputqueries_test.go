@@ -0,0 +1,68 @@
+package inputQueries
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadInputQueries(t *testing.T) {
+	content := `# Search engines
+gemini://kennedy.gemi.dev/search smolnet history
+gemini://Kennedy.gemi.dev:1965/search  gopher
+gemini://tlgs.one/search?old capsules
+`
+	tmpfile, err := os.CreateTemp("", "inputqueries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputQueries = nil
+	if err := loadInputQueries(tmpfile.Name()); err != nil {
+		t.Fatalf("loadInputQueries() error = %v", err)
+	}
+
+	tests := []struct {
+		endpoint string
+		want     []string
+	}{
+		{"gemini://kennedy.gemi.dev/search", []string{"smolnet history", "gopher"}},
+		{"gemini://kennedy.gemi.dev/search?something", []string{"smolnet history", "gopher"}},
+		{"gemini://tlgs.one/search", []string{"capsules"}},
+		{"gemini://tlgs.one/", nil},
+		{"gemini://other.example.com/search", nil},
+	}
+	for _, tt := range tests {
+		if got := QueriesFor(tt.endpoint); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueriesFor(%s) = %v, want %v", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestLoadInputQueriesWithoutQuery(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "inputqueries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte("gemini://kennedy.gemi.dev/search\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputQueries = nil
+	if err := loadInputQueries(tmpfile.Name()); err == nil {
+		t.Error("Expected an error for a line without a query")
+	}
+}
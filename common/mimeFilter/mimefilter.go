@@ -0,0 +1,33 @@
+package mimeFilter
+
+import (
+	"path"
+	"strings"
+
+	"gemini-grc/config"
+)
+
+// Allowed reports if the content of a MIME type should be
+// stored. Types matching config.CONFIG.SkipMimeTypes are
+// not; otherwise, types are stored if they match
+// config.CONFIG.StoreMimeTypes, or if it's empty.
+// Patterns use path.Match syntax, like image/*.
+func Allowed(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if matchesAny(config.CONFIG.SkipMimeTypes, mimeType) {
+		return false
+	}
+	if len(config.CONFIG.StoreMimeTypes) == 0 {
+		return true
+	}
+	return matchesAny(config.CONFIG.StoreMimeTypes, mimeType)
+}
+
+func matchesAny(patterns []string, mimeType string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), mimeType); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package mimeFilter
+
+import (
+	"testing"
+
+	"gemini-grc/config"
+)
+
+func TestAllowed(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+
+	tests := []struct {
+		name     string
+		store    []string
+		skip     []string
+		mimeType string
+		want     bool
+	}{
+		{"No lists", nil, nil, "application/zip", true},
+		{"Allowed by wildcard", []string{"text/*", "image/*"}, nil, "image/png", true},
+		{"Not allowed", []string{"text/*", "image/*"}, nil, "application/zip", false},
+		{"Case insensitive", []string{"text/*"}, nil, "Text/Gemini", true},
+		{"Denied", nil, []string{"application/octet-stream"}, "application/octet-stream", false},
+		{"Deny overrides allow", []string{"image/*"}, []string{"image/tiff"}, "image/tiff", false},
+		{"Not denied", []string{"image/*"}, []string{"image/tiff"}, "image/gif", true},
+		{"Any type", []string{"*/*"}, nil, "audio/ogg", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.CONFIG.StoreMimeTypes = tt.store
+			config.CONFIG.SkipMimeTypes = tt.skip
+			if got := Allowed(tt.mimeType); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package plaintext
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	// Replies with the request line repeated
+	// as many times as its length.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			line = strings.TrimSpace(line)
+			_, _ = conn.Write([]byte(strings.Repeat(line, len(line))))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	data, err := Fetch(ctx, address, []byte("ab\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abab", string(data))
+
+	_, err = Fetch(ctx, address, []byte(strings.Repeat("x", 64)+"\r\n"))
+	assert.ErrorContains(t, err, "response too large")
+
+	_, err = Fetch(ctx, "no-port", []byte("\r\n"))
+	assert.Error(t, err)
+}
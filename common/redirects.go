@@ -0,0 +1,160 @@
+package common
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gemini-grc/common/contextlog"
+	commonErrors "gemini-grc/common/errors"
+	"gemini-grc/common/hostBudget"
+	"gemini-grc/common/snapshot"
+	url2 "gemini-grc/common/url"
+	"gemini-grc/common/whiteList"
+	"gemini-grc/config"
+	gemdb "gemini-grc/db"
+	"gemini-grc/protocol"
+	"gemini-grc/robotsMatch"
+	"git.antanst.com/antanst/logging"
+	"github.com/guregu/null/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// redirectChain holds the URLs a worker visited
+// for a job, the first one and every redirect
+// target it followed since.
+type redirectChain struct {
+	urls  []string
+	depth int // Link depth of the job, redirects don't add to it
+}
+
+func newRedirectChain(start string, depth int) *redirectChain {
+	return &redirectChain{urls: []string{start}, depth: depth}
+}
+
+func (c *redirectChain) contains(u string) bool {
+	return slices.Contains(c.urls, u)
+}
+
+func (c *redirectChain) add(u string) {
+	c.urls = append(c.urls, u)
+}
+
+// canFollow reports if the worker can visit a redirect
+// target right away. The worker holds the host of the
+// job in the host pool, so only targets on that host
+// are followed; waiting for another host while holding
+// one could deadlock two workers. Hosts with a request
+// interval are left to the scheduler, which spaces
+// their visits through the host pool.
+func (c *redirectChain) canFollow(from *snapshot.Snapshot, target *url2.URL) bool {
+	hops := len(c.urls) - 1
+	return hops < config.CONFIG.MaxRedirects &&
+		target.Hostname == from.Host &&
+		config.CONFIG.ForHost(target.Hostname).RequestInterval == 0
+}
+
+// followRedirect records a redirect and visits its
+// target, or enqueues it if it can't be followed
+// right away. Permanent redirects map the URL to
+// its target, which becomes its canonical URL.
+func followRedirect(ctx context.Context, tx *sqlx.Tx, handler protocol.Handler, s *snapshot.Snapshot, chain *redirectChain) error {
+	target, err := url2.ExtractRedirectTargetFromHeader(s.URL, s.Header.ValueOrZero())
+	if err != nil {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Failed to extract redirect target: %v", err)
+		return err
+	}
+	code := int(s.ResponseCode.ValueOrZero())
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Page redirects to %s (%d)", target, code)
+
+	err = gemdb.Database.SaveRedirect(ctx, tx, &gemdb.Redirect{
+		FromURL: s.URL.Full,
+		ToURL:   target.Full,
+		Code:    code,
+		SeenAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if chain.contains(target.Full) {
+		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Redirect loop at %s", target)
+		s.Error = null.StringFrom(commonErrors.ErrRedirectLoop.Error())
+		return nil
+	}
+
+	if handler.IsPermanentRedirect(s) {
+		err = gemdb.Database.SaveCanonicalURL(ctx, tx, s.URL.Full, target.Full)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !shouldPersistURL(target) {
+		return nil
+	}
+	visited, err := haveWeVisitedURL(ctx, tx, target.Full)
+	if err != nil {
+		return err
+	}
+	if visited {
+		return nil
+	}
+
+	if !chain.canFollow(s, target) || (!whiteList.IsWhitelisted(target.String()) && robotsMatch.RobotMatch(ctx, tx, target.String())) {
+		return enqueueRedirectTarget(ctx, tx, target, chain.depth)
+	}
+
+	targetHandler, ok := protocol.ForURL(target.String())
+	if !ok {
+		return nil
+	}
+	chain.add(target.Full)
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Following redirect to %s", target)
+	next, err := visit(ctx, targetHandler, target.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// Keep the snapshot of the redirecting
+		// URL, and visit the target on its own.
+		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Failed to follow redirect to %s: %v", target, err)
+		return enqueueRedirectTarget(ctx, tx, target, chain.depth)
+	}
+	if next == nil {
+		return nil
+	}
+	return processSnapshot(ctx, tx, targetHandler, next, chain)
+}
+
+func enqueueRedirectTarget(ctx context.Context, tx *sqlx.Tx, target *url2.URL, depth int) error {
+	// The target's host may have a lower max depth.
+	if !hostBudget.For(target.Hostname).AllowsDepth(depth) {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Redirect target %s at depth %d exceeds the max depth of its host", target, depth)
+		return nil
+	}
+	err := gemdb.Database.InsertURLWithDepth(ctx, tx, target.Full, depth)
+	if err != nil {
+		return err
+	}
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Saved redirection URL %s", target)
+	return nil
+}
+
+// canonicalLink replaces a link to a URL that
+// moved permanently with a link to where it is.
+func canonicalLink(ctx context.Context, tx *sqlx.Tx, link url2.URL) (url2.URL, error) {
+	canonical, err := gemdb.Database.GetCanonicalURL(ctx, tx, link.Full)
+	if err != nil {
+		return link, err
+	}
+	if canonical == link.Full {
+		return link, nil
+	}
+	u, err := url2.ParseURL(canonical, link.Descr, true)
+	if err != nil {
+		return link, err
+	}
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Link %s moved to %s", link.Full, canonical)
+	return *u, nil
+}
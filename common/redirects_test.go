@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	url2 "gemini-grc/common/url"
+	"gemini-grc/config"
+	gemdb "gemini-grc/db"
+	"gemini-grc/protocol"
+	"github.com/guregu/null/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingHandler is a protocol whose
+// visits never produce a snapshot.
+type failingHandler struct{}
+
+func (failingHandler) Scheme() string   { return "failing" }
+func (failingHandler) DefaultPort() int { return 4343 }
+func (failingHandler) Enabled() bool    { return true }
+func (failingHandler) Visit(context.Context, string) (*snapshot.Snapshot, error) {
+	return nil, errors.New("no snapshot")
+}
+func (failingHandler) Links(*snapshot.Snapshot) linkList.LinkList  { return nil }
+func (failingHandler) IsRedirect(*snapshot.Snapshot) bool          { return true }
+func (failingHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
+
+func TestRedirectChainCanFollow(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.MaxRedirects = 2
+
+	from, err := snapshot.SnapshotFromURL("gemini://example.com/old", true)
+	assert.NoError(t, err)
+	sameHost, err := url2.ParseURL("gemini://example.com/new", "", true)
+	assert.NoError(t, err)
+	otherHost, err := url2.ParseURL("gemini://example.org/new", "", true)
+	assert.NoError(t, err)
+
+	chain := newRedirectChain(from.URL.Full, 0)
+	assert.True(t, chain.canFollow(from, sameHost))
+	assert.False(t, chain.canFollow(from, otherHost))
+
+	chain.add("gemini://example.com/a")
+	assert.True(t, chain.canFollow(from, sameHost))
+	chain.add("gemini://example.com/b")
+	assert.False(t, chain.canFollow(from, sameHost), "hop limit reached")
+
+	config.CONFIG.RequestInterval = 1
+	chain = newRedirectChain(from.URL.Full, 0)
+	assert.False(t, chain.canFollow(from, sameHost), "host has a request interval")
+}
+
+func TestRedirectChainContains(t *testing.T) {
+	t.Parallel()
+	chain := newRedirectChain("gemini://example.com/a", 0)
+	chain.add("gemini://example.com/b")
+
+	assert.True(t, chain.contains("gemini://example.com/a"))
+	assert.True(t, chain.contains("gemini://example.com/b"))
+	assert.False(t, chain.contains("gemini://example.com/c"))
+}
+
+func TestFollowRedirectEnqueuesTargetOnVisitError(t *testing.T) {
+	originalConfig := config.CONFIG
+	originalDatabase := gemdb.Database
+	defer func() {
+		config.CONFIG = originalConfig
+		gemdb.Database = originalDatabase
+	}()
+	config.CONFIG.MaxRedirects = 5
+	protocol.Register(failingHandler{})
+
+	ctx := context.Background()
+	gemdb.Database = gemdb.NewMemoryDbService(io.Discard)
+	require.NoError(t, gemdb.Database.Initialize(ctx))
+	tx, err := gemdb.Database.NewTx(ctx)
+	require.NoError(t, err)
+
+	s, err := snapshot.SnapshotFromURL("failing://example.com/old", true)
+	require.NoError(t, err)
+	s.ResponseCode = null.IntFrom(30)
+	s.Header = null.StringFrom("30 /new")
+
+	handler, _ := protocol.Get("failing")
+	err = followRedirect(ctx, tx, handler, s, newRedirectChain(s.URL.Full, 0))
+	require.NoError(t, err)
+
+	queued, err := gemdb.Database.IsURLQueued(ctx, tx, "failing://example.com:4343/new")
+	require.NoError(t, err)
+	assert.True(t, queued)
+}
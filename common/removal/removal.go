@@ -0,0 +1,96 @@
+package removal
+
+import (
+	"context"
+	"os/user"
+	"slices"
+
+	gemdb "gemini-grc/db"
+	"gemini-grc/util"
+	"github.com/jmoiron/sqlx"
+)
+
+// Removes URLs from the queue and snapshots from the
+// archive for the blacklist and takedown commands,
+// logging every removal to `removal_log`. URLs are
+// removed and logged in batches, one statement each.
+
+// How many URLs are removed per statement.
+const batchSize = 500
+
+// CurrentUser returns the name of the user running
+// the command, the default of who removes URLs.
+func CurrentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// RemoveQueued deletes queued URLs. Their log entries
+// take RemovedBy, Reason and RemovedAt from by.
+func RemoveQueued(ctx context.Context, tx *sqlx.Tx, urls []string, by gemdb.Removal) error {
+	for batch := range slices.Chunk(urls, batchSize) {
+		err := gemdb.Database.DeleteURLs(ctx, tx, batch)
+		if err != nil {
+			return err
+		}
+		removals := util.Map(batch, func(url string) gemdb.Removal {
+			r := by
+			r.URL = url
+			r.Target = gemdb.RemovalTargetQueue
+			r.Action = gemdb.RemovalActionDelete
+			return r
+		})
+		err = gemdb.Database.LogRemovals(ctx, tx, removals)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveSnapshots marks, deletes or tombstones the
+// snapshots of URLs, depending on action. Their log
+// entries take RemovedBy, Reason and RemovedAt from by.
+// It returns the number of URLs that had snapshots
+// removed, and the number of snapshots.
+func RemoveSnapshots(ctx context.Context, tx *sqlx.Tx, urls []string, action string, by gemdb.Removal) (int, int64, error) {
+	removedURLs := 0
+	var total int64
+	for batch := range slices.Chunk(urls, batchSize) {
+		var removed map[string]int64
+		var err error
+		switch action {
+		case gemdb.RemovalActionMark:
+			removed, err = gemdb.Database.MarkSnapshotsRemoved(ctx, tx, batch)
+		case gemdb.RemovalActionDelete:
+			removed, err = gemdb.Database.DeleteSnapshots(ctx, tx, batch)
+		case gemdb.RemovalActionTombstone:
+			removed, err = gemdb.Database.TombstoneSnapshots(ctx, tx, batch)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		var removals []gemdb.Removal
+		for _, url := range batch {
+			if removed[url] == 0 {
+				continue
+			}
+			r := by
+			r.URL = url
+			r.Target = gemdb.RemovalTargetSnapshots
+			r.Action = action
+			r.Snapshots = removed[url]
+			removals = append(removals, r)
+			removedURLs++
+			total += removed[url]
+		}
+		err = gemdb.Database.LogRemovals(ctx, tx, removals)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return removedURLs, total, nil
+}
@@ -0,0 +1,58 @@
+package removal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"gemini-grc/common/snapshot"
+	gemdb "gemini-grc/db"
+	"github.com/guregu/null/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemove(t *testing.T) {
+	originalDatabase := gemdb.Database
+	defer func() {
+		gemdb.Database = originalDatabase
+	}()
+
+	ctx := context.Background()
+	gemdb.Database = gemdb.NewMemoryDbService(io.Discard)
+	require.NoError(t, gemdb.Database.Initialize(ctx))
+	tx, err := gemdb.Database.NewTx(ctx)
+	require.NoError(t, err)
+
+	// More than one batch
+	var urls []string
+	for i := range batchSize + 1 {
+		url := fmt.Sprintf("gemini://example.org:1965/%d", i)
+		require.NoError(t, gemdb.Database.InsertURLWithDepth(ctx, tx, url, 0))
+		urls = append(urls, url)
+	}
+	for range 2 {
+		s, err := snapshot.SnapshotFromURL(urls[batchSize], true)
+		require.NoError(t, err)
+		s.GemText = null.StringFrom("# Hello\n")
+		require.NoError(t, gemdb.Database.SaveSnapshot(ctx, tx, s))
+	}
+
+	by := gemdb.Removal{RemovedBy: "admin", Reason: "Asked", RemovedAt: time.Now()}
+	require.NoError(t, RemoveQueued(ctx, tx, urls, by))
+	queued, err := gemdb.Database.GetQueuedURLs(ctx, tx, gemdb.URLFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+
+	removedURLs, removed, err := RemoveSnapshots(ctx, tx, urls, gemdb.RemovalActionTombstone, by)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removedURLs)
+	assert.Equal(t, int64(2), removed)
+
+	removedURLs, removed, err = RemoveSnapshots(ctx, tx, urls, gemdb.RemovalActionTombstone, by)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removedURLs)
+	assert.Equal(t, int64(0), removed)
+}
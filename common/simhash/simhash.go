@@ -0,0 +1,65 @@
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// A SimHash is a 64-bit fingerprint of a text where
+// similar texts get fingerprints that differ in few
+// bits. Every pair of consecutive words is hashed,
+// and each bit of the fingerprint is set if most
+// word pairs have that bit set.
+
+// shingleSize is how many consecutive words make a feature.
+const shingleSize = 2
+
+// Fingerprint returns the SimHash of a text,
+// and false if the text has no words.
+func Fingerprint(text string) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0, false
+	}
+
+	var weights [64]int
+	h := fnv.New64a()
+	shingles := max(len(words)-shingleSize+1, 1)
+	for i := range shingles {
+		end := min(i+shingleSize, len(words))
+		h.Reset()
+		_, _ = h.Write([]byte(strings.Join(words[i:end], " ")))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint, true
+}
+
+// Distance returns the number of
+// bits two fingerprints differ in.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similarity returns how similar two fingerprints
+// are, from 0 (opposite) to 1 (the same).
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(Distance(a, b))/64
+}
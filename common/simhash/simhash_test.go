@@ -0,0 +1,77 @@
+package simhash
+
+import (
+	"strings"
+	"testing"
+)
+
+const page = `# My capsule
+
+Welcome to my little corner of Geminispace. Here I write about
+vintage computers, gardening and the occasional book review.
+
+## Recent posts
+=> /posts/2024-05-01.gmi Repairing an Amiga 500 power supply
+=> /posts/2024-04-12.gmi Tomatoes, again
+=> /posts/2024-03-30.gmi Notes on a Tale of Two Cities
+
+Visitors: 1234
+`
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	a, ok := Fingerprint(page)
+	if !ok {
+		t.Fatal("Fingerprint() found no words")
+	}
+
+	// Same words, different formatting
+	b, _ := Fingerprint(strings.ToUpper(strings.ReplaceAll(page, "\n", "  \n")))
+	if a != b {
+		t.Errorf("fingerprints differ for the same words: %x != %x", a, b)
+	}
+
+	// A visit counter changed
+	c, _ := Fingerprint(strings.Replace(page, "1234", "1235", 1))
+	if s := Similarity(a, c); s < 0.9 {
+		t.Errorf("Similarity() of near duplicates = %f, want >= 0.9", s)
+	}
+
+	// A different page
+	d, _ := Fingerprint(`# Weather report
+
+Rain all week across the northern valleys, with strong winds on
+the coast. Temperatures drop below zero at night in the mountains.
+Farmers should protect young plants and check drainage channels.`)
+	if s := Similarity(a, d); s > 0.8 {
+		t.Errorf("Similarity() of different pages = %f, want <= 0.8", s)
+	}
+
+	if _, ok := Fingerprint(" \n=> / *"); ok {
+		t.Error("Fingerprint() of text without words should fail")
+	}
+	if one, ok := Fingerprint("hello"); !ok || one == 0 {
+		t.Error("Fingerprint() of a single word should work")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0b1010, 0b0101, 4},
+		{0, ^uint64(0), 64},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := Similarity(0, ^uint64(0)); got != 0 {
+		t.Errorf("Similarity() of opposites = %f, want 0", got)
+	}
+}
@@ -23,6 +23,18 @@ type Snapshot struct {
 	ResponseCode null.Int                      `db:"response_code" json:"code,omitempty"`        // Gemini response Status code.
 	Error        null.String                   `db:"error" json:"error,omitempty"`               // On network errors only
 	LastCrawled  null.Time                     `db:"last_crawled" json:"last_crawled,omitempty"` // When URL was last processed (regardless of content changes)
+	SimHash      null.Int                      `db:"simhash" json:"simhash,omitempty"`           // Fingerprint of text content, for near-duplicates.
+	RemovedAt    null.Time                     `db:"removed_at" json:"removed_at,omitempty"`     // Hidden from the archive since
+	// Content not stored because of its MIME type
+	SkippedContent bool `db:"skipped_content" json:"skipped_content,omitempty"`
+}
+
+// SkipContent drops the content of a snapshot, keeping
+// its header and links so the crawl goes on.
+func (s *Snapshot) SkipContent() {
+	s.Data = null.Value[[]byte]{}
+	s.GemText = null.String{}
+	s.SkippedContent = true
 }
 
 func SnapshotFromURL(u string, normalize bool) (*Snapshot, error) {
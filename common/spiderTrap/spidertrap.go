@@ -0,0 +1,167 @@
+package spiderTrap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	url2 "gemini-grc/common/url"
+	"gemini-grc/config"
+)
+
+// Capsules that generate URLs on the fly, like calendars
+// with endless "next month" links or relative links that
+// keep nesting, would fill the queue forever. Check looks
+// at a URL before it's enqueued and flags it as a trap if:
+//
+//   - its path is deeper than config.CONFIG.TrapMaxPathDepth
+//   - a path segment repeats more than config.CONFIG.TrapMaxRepeatedSegments times
+//   - its path was enqueued with more than config.CONFIG.TrapMaxQueryVariants
+//     different queries within the last window
+//   - its host had more than config.CONFIG.TrapMaxHostURLsPerHour URLs
+//     enqueued within the last window
+//
+// Limits set to 0 are not checked. For the last two, URLs
+// are counted in memory once Record says they were enqueued,
+// and counting starts over every window.
+
+const window = time.Hour
+
+type hostStats struct {
+	urls    map[string]struct{}            // URLs enqueued in the window
+	queries map[string]map[string]struct{} // Queries per path in the window
+}
+
+type detector struct {
+	hosts       map[string]*hostStats
+	windowStart time.Time
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+var trapDetector = newDetector(time.Now) //nolint:gochecknoglobals
+
+func newDetector(now func() time.Time) *detector {
+	return &detector{
+		hosts:       make(map[string]*hostStats),
+		windowStart: now(),
+		now:         now,
+	}
+}
+
+// Check returns why a URL looks like a spider trap,
+// or false if it doesn't. It doesn't count the URL,
+// see Record.
+func Check(u *url2.URL) (reason string, isTrap bool) {
+	return trapDetector.check(u)
+}
+
+// Record counts an enqueued URL towards
+// the limits of its host.
+func Record(u *url2.URL) {
+	trapDetector.record(u)
+}
+
+func (d *detector) check(u *url2.URL) (string, bool) {
+	if reason, isTrap := checkPath(u.Path); isTrap {
+		return reason, true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := d.statsFor(u.Hostname)
+	if _, seen := stats.urls[u.Full]; seen {
+		return "", false
+	}
+
+	maxURLs := config.CONFIG.TrapMaxHostURLsPerHour
+	if maxURLs > 0 && len(stats.urls) >= maxURLs {
+		return fmt.Sprintf("more than %d new URLs from %s within %s", maxURLs, u.Hostname, window), true
+	}
+
+	if query := queryOf(u); query != "" {
+		maxVariants := config.CONFIG.TrapMaxQueryVariants
+		variants := stats.queries[u.Path]
+		if _, seen := variants[query]; !seen && maxVariants > 0 && len(variants) >= maxVariants {
+			return fmt.Sprintf("more than %d query variants of %s within %s", maxVariants, u.Path, window), true
+		}
+	}
+
+	return "", false
+}
+
+func (d *detector) record(u *url2.URL) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := d.statsFor(u.Hostname)
+	stats.urls[u.Full] = struct{}{}
+	if query := queryOf(u); query != "" {
+		variants := stats.queries[u.Path]
+		if variants == nil {
+			variants = make(map[string]struct{})
+			stats.queries[u.Path] = variants
+		}
+		variants[query] = struct{}{}
+	}
+}
+
+// statsFor returns the counts of a host in the current
+// window, starting a new window if it's over. Must be
+// called with d.mu held.
+func (d *detector) statsFor(host string) *hostStats {
+	if d.now().Sub(d.windowStart) >= window {
+		d.hosts = make(map[string]*hostStats)
+		d.windowStart = d.now()
+	}
+
+	stats, ok := d.hosts[host]
+	if !ok {
+		stats = &hostStats{
+			urls:    make(map[string]struct{}),
+			queries: make(map[string]map[string]struct{}),
+		}
+		d.hosts[host] = stats
+	}
+	return stats
+}
+
+// checkPath applies the heuristics that
+// only need the path of a URL.
+func checkPath(path string) (string, bool) {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	maxDepth := config.CONFIG.TrapMaxPathDepth
+	if maxDepth > 0 && len(segments) > maxDepth {
+		return fmt.Sprintf("path depth %d exceeds %d", len(segments), maxDepth), true
+	}
+
+	maxRepeats := config.CONFIG.TrapMaxRepeatedSegments
+	if maxRepeats > 0 {
+		counts := make(map[string]int, len(segments))
+		for _, segment := range segments {
+			counts[segment]++
+			if counts[segment] > maxRepeats {
+				return fmt.Sprintf("path segment %q repeated more than %d times", segment, maxRepeats), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func queryOf(u *url2.URL) string {
+	_, query, found := strings.Cut(u.Full, "?")
+	if !found {
+		return ""
+	}
+	query, _, _ = strings.Cut(query, "#")
+	return query
+}
@@ -0,0 +1,122 @@
+package spiderTrap
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	url2 "gemini-grc/common/url"
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func setLimits(t *testing.T, depth, repeats, variants, hostURLs int) {
+	t.Helper()
+	originalConfig := config.CONFIG
+	t.Cleanup(func() {
+		config.CONFIG = originalConfig
+	})
+	config.CONFIG.TrapMaxPathDepth = depth
+	config.CONFIG.TrapMaxRepeatedSegments = repeats
+	config.CONFIG.TrapMaxQueryVariants = variants
+	config.CONFIG.TrapMaxHostURLsPerHour = hostURLs
+}
+
+func mustParse(t *testing.T, u string) *url2.URL {
+	t.Helper()
+	parsed, err := url2.ParseURL(u, "", true)
+	assert.NoError(t, err)
+	return parsed
+}
+
+func TestCheckPath(t *testing.T) {
+	setLimits(t, 5, 2, 0, 0)
+	tests := []struct {
+		path   string
+		isTrap bool
+	}{
+		{"/", false},
+		{"/a/b/c/d/e", false},
+		{"/a/b/c/d/e/f", true},
+		{"/docs/docs/index.gmi", false},
+		{"/docs/x/docs/y/docs", true},
+	}
+	for _, tt := range tests {
+		reason, isTrap := checkPath(tt.path)
+		assert.Equal(t, tt.isTrap, isTrap, tt.path)
+		assert.Equal(t, tt.isTrap, reason != "", tt.path)
+	}
+}
+
+// checkAndRecord checks a URL, and records it
+// if it passes, as storeLinks does.
+func checkAndRecord(t *testing.T, d *detector, u string) (string, bool) {
+	t.Helper()
+	parsed := mustParse(t, u)
+	reason, isTrap := d.check(parsed)
+	if !isTrap {
+		d.record(parsed)
+	}
+	return reason, isTrap
+}
+
+func TestCheckQueryVariants(t *testing.T) {
+	setLimits(t, 0, 0, 3, 0)
+	d := newDetector(time.Now)
+
+	for day := 1; day <= 3; day++ {
+		_, isTrap := checkAndRecord(t, d, fmt.Sprintf("gemini://example.com/calendar?day=%d", day))
+		assert.False(t, isTrap)
+	}
+	// Known URLs are fine
+	_, isTrap := checkAndRecord(t, d, "gemini://example.com/calendar?day=1")
+	assert.False(t, isTrap)
+
+	reason, isTrap := checkAndRecord(t, d, "gemini://example.com/calendar?day=4")
+	assert.True(t, isTrap)
+	assert.Contains(t, reason, "/calendar")
+
+	// Other paths and hosts are counted on their own
+	_, isTrap = checkAndRecord(t, d, "gemini://example.com/search?day=4")
+	assert.False(t, isTrap)
+	_, isTrap = checkAndRecord(t, d, "gemini://example.org/calendar?day=4")
+	assert.False(t, isTrap)
+}
+
+func TestCheckHostGrowth(t *testing.T) {
+	setLimits(t, 0, 0, 0, 2)
+	now := time.Now()
+	d := newDetector(func() time.Time { return now })
+
+	_, isTrap := checkAndRecord(t, d, "gemini://example.com/1")
+	assert.False(t, isTrap)
+	_, isTrap = checkAndRecord(t, d, "gemini://example.com/2")
+	assert.False(t, isTrap)
+	_, isTrap = checkAndRecord(t, d, "gemini://example.com/3")
+	assert.True(t, isTrap)
+
+	// Counting starts over with the next window
+	now = now.Add(window)
+	_, isTrap = checkAndRecord(t, d, "gemini://example.com/3")
+	assert.False(t, isTrap)
+}
+
+func TestCheckDoesNotCount(t *testing.T) {
+	setLimits(t, 0, 0, 1, 1)
+	d := newDetector(time.Now)
+
+	// Links checked but not enqueued, e.g. over the host
+	// budget, don't count towards the limits.
+	for range 3 {
+		_, isTrap := d.check(mustParse(t, "gemini://example.com/calendar?day=1"))
+		assert.False(t, isTrap)
+		_, isTrap = d.check(mustParse(t, "gemini://example.com/calendar?day=2"))
+		assert.False(t, isTrap)
+	}
+
+	d.record(mustParse(t, "gemini://example.com/calendar?day=1"))
+	_, isTrap := d.check(mustParse(t, "gemini://example.com/calendar?day=1"))
+	assert.False(t, isTrap)
+	_, isTrap = d.check(mustParse(t, "gemini://example.com/calendar?day=2"))
+	assert.True(t, isTrap)
+}
@@ -0,0 +1,214 @@
+package takedown
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/common/url"
+	"gemini-grc/contextutil"
+	gemdb "gemini-grc/db"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+)
+
+// Takedown rules keep URLs whose authors opted
+// out of the crawl, even whitelisted ones. Rules live
+// in the database so the takedown command can add
+// them while the crawler runs; the crawler keeps a
+// copy in memory and refreshes it periodically.
+
+const refreshInterval = time.Minute
+
+type rule struct {
+	gemdb.TakedownRule
+	regex *regexp.Regexp // For regex rules only
+}
+
+var (
+	rules   []rule       //nolint:gochecknoglobals
+	rulesMu sync.RWMutex //nolint:gochecknoglobals
+	// Set once rules are loaded from the database.
+	// Tests and tools without a database leave it unset.
+	persisted atomic.Bool //nolint:gochecknoglobals
+)
+
+func Initialize() error {
+	setRules(nil)
+	return nil
+}
+
+func Shutdown() error {
+	persisted.Store(false)
+	return nil
+}
+
+// Load reads the active rules from the database.
+// Must be called after the database is initialized.
+func Load(ctx context.Context) error {
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gemdb.SafeRollback(ctx, tx)
+	}()
+
+	dbRules, err := gemdb.Database.GetTakedownRules(ctx, tx, false)
+	if err != nil {
+		return err
+	}
+	compiled := make([]rule, 0, len(dbRules))
+	for _, r := range dbRules {
+		c, err := compile(r)
+		if err != nil {
+			// Skipping a rule would crawl what
+			// it covers, so refuse the whole set.
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+	if len(compiled) != countRules() {
+		logging.LogInfo("Loaded %d takedown rules", len(compiled))
+	}
+	setRules(compiled)
+	persisted.Store(true)
+	return nil
+}
+
+// RunRefresher reloads the rules periodically,
+// so rules added while crawling take effect.
+// Runs until ctx is canceled.
+func RunRefresher(ctx context.Context) {
+	ctx = contextutil.ContextWithComponent(ctx, "takedownRefresher")
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !persisted.Load() {
+			continue
+		}
+		if err := Load(ctx); err != nil {
+			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Keeping current takedown rules: %v", err)
+		}
+	}
+}
+
+// Match returns the first active rule matching a URL.
+func Match(u string) (gemdb.TakedownRule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	for _, r := range rules {
+		if r.matches(u) {
+			return r.TakedownRule, true
+		}
+	}
+	return gemdb.TakedownRule{}, false
+}
+
+// Matches reports if a rule matches a URL.
+func Matches(r gemdb.TakedownRule, u string) (bool, error) {
+	c, err := compile(r)
+	if err != nil {
+		return false, err
+	}
+	return c.matches(u), nil
+}
+
+// Filter returns the database filter that narrows
+// down the URLs a rule can match. Regexes are only
+// matched in Go, so every URL is a candidate.
+func Filter(r gemdb.TakedownRule) gemdb.URLFilter {
+	switch r.Kind {
+	case gemdb.TakedownKindHost:
+		return gemdb.URLFilter{Host: r.Pattern}
+	case gemdb.TakedownKindPrefix:
+		return gemdb.URLFilter{Prefix: r.Pattern}
+	}
+	return gemdb.URLFilter{}
+}
+
+// NormalizePattern validates the pattern of a rule, and
+// returns it in the form URLs are stored in: hostnames
+// lowercased, prefixes normalized like URLs are. Prefixes
+// must end with a slash, so that they match whole path
+// segments: `/~al/` doesn't match `/~alice/`.
+func NormalizePattern(kind string, pattern string) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return "", xerrors.NewError(fmt.Errorf("empty takedown pattern"), 0, "", false)
+	}
+	switch kind {
+	case gemdb.TakedownKindHost:
+		if strings.ContainsAny(pattern, ":/") {
+			return "", xerrors.NewError(fmt.Errorf("takedown host %s should be a hostname only", pattern), 0, "", false)
+		}
+		return strings.ToLower(pattern), nil
+	case gemdb.TakedownKindPrefix:
+		u, err := url.ParseURL(pattern, "", true)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(u.Full, "/") {
+			return "", xerrors.NewError(fmt.Errorf("takedown prefix %s should end with /, or be a regex", pattern), 0, "", false)
+		}
+		return u.Full, nil
+	case gemdb.TakedownKindRegex:
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", xerrors.NewError(fmt.Errorf("invalid takedown regex %s: %w", pattern, err), 0, "", false)
+		}
+		return pattern, nil
+	default:
+		return "", xerrors.NewError(fmt.Errorf("invalid takedown rule kind %s", kind), 0, "", false)
+	}
+}
+
+func compile(r gemdb.TakedownRule) (rule, error) {
+	c := rule{TakedownRule: r}
+	switch r.Kind {
+	case gemdb.TakedownKindHost, gemdb.TakedownKindPrefix:
+	case gemdb.TakedownKindRegex:
+		regex, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return rule{}, xerrors.NewError(fmt.Errorf("invalid regex in takedown rule %d: %w", r.ID, err), 0, "", false)
+		}
+		c.regex = regex
+	default:
+		return rule{}, xerrors.NewError(fmt.Errorf("invalid kind of takedown rule %d: %s", r.ID, r.Kind), 0, "", false)
+	}
+	return c, nil
+}
+
+func (r rule) matches(u string) bool {
+	switch r.Kind {
+	case gemdb.TakedownKindHost:
+		parsed, err := url.ParseURL(u, "", false)
+		return err == nil && strings.EqualFold(parsed.Hostname, r.Pattern)
+	case gemdb.TakedownKindPrefix:
+		return strings.HasPrefix(u, r.Pattern)
+	case gemdb.TakedownKindRegex:
+		return r.regex.MatchString(u)
+	}
+	return false
+}
+
+func setRules(r []rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules = r
+}
+
+func countRules() int {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return len(rules)
+}
@@ -0,0 +1,107 @@
+package takedown
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gemdb "gemini-grc/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		pattern  string
+		expected string
+		wantErr  bool
+	}{
+		{"host", gemdb.TakedownKindHost, " Example.ORG ", "example.org", false},
+		{"host with scheme", gemdb.TakedownKindHost, "gemini://example.org", "", true},
+		{"host with port", gemdb.TakedownKindHost, "example.org:1965", "", true},
+		{"prefix", gemdb.TakedownKindPrefix, "gemini://Example.org/~bob/", "gemini://example.org:1965/~bob/", false},
+		{"prefix without scheme", gemdb.TakedownKindPrefix, "example.org/~bob/", "", true},
+		{"prefix without trailing slash", gemdb.TakedownKindPrefix, "gemini://example.org/~al", "", true},
+		{"capsule root", gemdb.TakedownKindPrefix, "gemini://example.org/", "gemini://example.org:1965/", false},
+		{"regex", gemdb.TakedownKindRegex, `^gemini://example\.org:1965/private/`, `^gemini://example\.org:1965/private/`, false},
+		{"invalid regex", gemdb.TakedownKindRegex, "[invalid", "", true},
+		{"empty", gemdb.TakedownKindHost, "  ", "", true},
+		{"unknown kind", "path", "/private", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePattern(tt.kind, tt.pattern)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	defer setRules(nil)
+
+	var compiled []rule
+	for _, r := range []gemdb.TakedownRule{
+		{ID: 1, Kind: gemdb.TakedownKindHost, Pattern: "example.org"},
+		{ID: 2, Kind: gemdb.TakedownKindPrefix, Pattern: "gemini://example.com:1965/~bob/"},
+		{ID: 3, Kind: gemdb.TakedownKindRegex, Pattern: `/private/`},
+	} {
+		c, err := compile(r)
+		assert.NoError(t, err)
+		compiled = append(compiled, c)
+	}
+	setRules(compiled)
+
+	tests := []struct {
+		url  string
+		rule int // 0 for no match
+	}{
+		{"gemini://example.org:1965/", 1},
+		{"gopher://example.org:70/1/", 1},
+		{"gemini://sub.example.org:1965/", 0},
+		{"gemini://example.com:1965/~bob/gemlog/1.gmi", 2},
+		{"gemini://example.com:1965/~alice/", 0},
+		{"gemini://example.net:1965/private/notes.gmi", 3},
+		{"gemini://example.net:1965/public/", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			r, ok := Match(tt.url)
+			assert.Equal(t, tt.rule != 0, ok)
+			assert.Equal(t, tt.rule, r.ID)
+		})
+	}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	_, err := compile(gemdb.TakedownRule{ID: 1, Kind: gemdb.TakedownKindRegex, Pattern: "[invalid"})
+	assert.Error(t, err)
+	_, err = compile(gemdb.TakedownRule{ID: 2, Kind: "path", Pattern: "/"})
+	assert.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	assert.Equal(t, gemdb.URLFilter{Host: "example.org"}, Filter(gemdb.TakedownRule{Kind: gemdb.TakedownKindHost, Pattern: "example.org"}))
+	assert.Equal(t, gemdb.URLFilter{Prefix: "gemini://example.org:1965/~bob/"}, Filter(gemdb.TakedownRule{Kind: gemdb.TakedownKindPrefix, Pattern: "gemini://example.org:1965/~bob/"}))
+	assert.Equal(t, gemdb.URLFilter{}, Filter(gemdb.TakedownRule{Kind: gemdb.TakedownKindRegex, Pattern: "bob"}))
+}
+
+func TestRunRefresherStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunRefresher(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunRefresher did not stop after cancel")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"git.antanst.com/antanst/xerrors"
 )
@@ -44,14 +45,8 @@ func (u URL) String() string {
 }
 
 func (u URL) StringNoDefaultPort() string {
-	if IsGeminiUrl(u.String()) {
-		if u.Port == 1965 {
-			return fmt.Sprintf("%s://%s%s", u.Protocol, u.Hostname, u.Path)
-		}
-	} else {
-		if u.Port == 70 {
-			return fmt.Sprintf("%s://%s%s", u.Protocol, u.Hostname, u.Path)
-		}
+	if port, ok := DefaultPort(u.Protocol); ok && u.Port == port {
+		return fmt.Sprintf("%s://%s%s", u.Protocol, u.Hostname, u.Path)
 	}
 	return u.Full
 }
@@ -63,6 +58,38 @@ func (u URL) Value() (driver.Value, error) {
 	return u.Full, nil
 }
 
+// Default ports of the schemes we know about.
+// Protocol handlers read theirs from here, and
+// handlers of other schemes register theirs,
+// see protocol.Register.
+var defaultPorts = map[string]int{ //nolint:gochecknoglobals
+	"http":    80,
+	"https":   443,
+	"gemini":  1965,
+	"gopher":  70,
+	"spartan": 300,
+	"nex":     1900,
+	"finger":  79,
+}
+
+var defaultPortsMu sync.RWMutex //nolint:gochecknoglobals
+
+// RegisterDefaultPort sets the default port of a scheme,
+// which ParseURL fills in and NormalizeURL removes.
+func RegisterDefaultPort(scheme string, port int) {
+	defaultPortsMu.Lock()
+	defer defaultPortsMu.Unlock()
+	defaultPorts[strings.ToLower(scheme)] = port
+}
+
+// DefaultPort returns the default port of a scheme.
+func DefaultPort(scheme string) (int, bool) {
+	defaultPortsMu.RLock()
+	defer defaultPortsMu.RUnlock()
+	port, ok := defaultPorts[strings.ToLower(scheme)]
+	return port, ok
+}
+
 func IsGeminiUrl(url string) bool {
 	return strings.HasPrefix(url, "gemini://")
 }
@@ -91,11 +118,11 @@ func ParseURL(input string, descr string, normalize bool) (*URL, error) {
 	// urlPath := u.EscapedPath()
 	urlPath := u.Path
 	if strPort == "" {
-		if u.Scheme == "gemini" {
-			strPort = "1965" // default Gemini port
-		} else {
-			strPort = "70" // default Gopher port
+		port, ok := DefaultPort(u.Scheme)
+		if !ok {
+			return nil, xerrors.NewError(fmt.Errorf("error parsing URL: unknown scheme %q without a port: %s", u.Scheme, input), 0, "", false)
 		}
+		strPort = strconv.Itoa(port)
 	}
 	port, err := strconv.Atoi(strPort)
 	if err != nil {
@@ -140,6 +167,7 @@ func DeriveAbsoluteURL(currentURL URL, input string) (*URL, error) {
 // - Proper escaping of special characters
 // - Lowercase scheme and host
 // - Removal of default ports
+// - finger://user@host becomes finger://host/user
 // - Empty path becomes "/"
 func NormalizeURL(rawURL string) (*url.URL, error) {
 	// Parse the URL
@@ -164,18 +192,17 @@ func NormalizeURL(rawURL string) (*url.URL, error) {
 
 	// remove default ports
 	if u.Port() != "" {
-		switch {
-		case u.Scheme == "http" && u.Port() == "80":
-			u.Host = u.Hostname()
-		case u.Scheme == "https" && u.Port() == "443":
-			u.Host = u.Hostname()
-		case u.Scheme == "gemini" && u.Port() == "1965":
-			u.Host = u.Hostname()
-		case u.Scheme == "gopher" && u.Port() == "70":
+		if port, ok := DefaultPort(u.Scheme); ok && u.Port() == strconv.Itoa(port) {
 			u.Host = u.Hostname()
 		}
 	}
 
+	// finger://user@host is the same as finger://host/user
+	if u.Scheme == "finger" && u.User != nil {
+		u.Path = "/" + u.User.Username()
+		u.User = nil
+	}
+
 	// Handle path normalization while preserving trailing slash
 	if u.Path != "" {
 		// Check if there was a trailing slash before cleaning
@@ -25,6 +25,36 @@ func TestURLOperations(t *testing.T) {
 				absolute: true,
 				want:     "gemini://caolan.uk:1965/cgi-bin/weather.py/wxfcs/3162",
 			},
+			{
+				name:     "parse Spartan URL default port",
+				input:    "spartan://mozz.us/",
+				absolute: true,
+				want:     "spartan://mozz.us:300/",
+			},
+			{
+				name:     "parse Nex URL default port",
+				input:    "nex://nightfall.city/nex/",
+				absolute: true,
+				want:     "nex://nightfall.city:1900/nex/",
+			},
+			{
+				name:     "parse Finger URL with user info",
+				input:    "finger://alice@example.com",
+				absolute: true,
+				want:     "finger://example.com:79/alice",
+			},
+			{
+				name:     "parse unknown scheme with port",
+				input:    "xyz://example.com:1234/",
+				absolute: true,
+				want:     "xyz://example.com:1234/",
+			},
+			{
+				name:     "parse unknown scheme without port",
+				input:    "xyz://example.com/",
+				absolute: true,
+				wantErr:  true,
+			},
 		}
 
 		for _, tt := range tests {
@@ -217,6 +247,31 @@ func TestNormalizeURL(t *testing.T) {
 			input:    "gemini://gemi.dev/cgi-bin/xkcd.cgi?1494",
 			expected: "gemini://gemi.dev/cgi-bin/xkcd.cgi?1494",
 		},
+		{
+			name:     "Spartan URL with default port",
+			input:    "SPARTAN://Mozz.US:300/",
+			expected: "spartan://mozz.us/",
+		},
+		{
+			name:     "Nex URL with default port",
+			input:    "nex://nightfall.city:1900/nex/",
+			expected: "nex://nightfall.city/nex/",
+		},
+		{
+			name:     "Finger URL with user info",
+			input:    "finger://Alice@Example.com",
+			expected: "finger://example.com/Alice",
+		},
+		{
+			name:     "Finger URL with default port",
+			input:    "finger://example.com:79/alice",
+			expected: "finger://example.com/alice",
+		},
+		{
+			name:     "Spartan URL with non-default port",
+			input:    "spartan://mozz.us:3000/notes",
+			expected: "spartan://mozz.us:3000/notes",
+		},
 	}
 
 	for _, tt := range tests {
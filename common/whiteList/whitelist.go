@@ -5,13 +5,21 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"gemini-grc/common/fileWatch"
 	"gemini-grc/config"
+	"gemini-grc/util"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
 )
 
-var whitelist []regexp.Regexp //nolint:gochecknoglobals
+var (
+	whitelist    []regexp.Regexp //nolint:gochecknoglobals
+	whitelistMu  sync.RWMutex    //nolint:gochecknoglobals
+	stopWatching func()          //nolint:gochecknoglobals
+)
 
 func Initialize() error {
 	var err error
@@ -21,24 +29,81 @@ func Initialize() error {
 		if err = loadWhitelist(config.CONFIG.WhitelistPath); err != nil {
 			return err
 		}
+		if config.CONFIG.ListsWatchInterval > 0 {
+			interval := time.Duration(config.CONFIG.ListsWatchInterval) * time.Second
+			stopWatching = fileWatch.Watch(config.CONFIG.WhitelistPath, interval, func() {
+				if err := Reload(); err != nil {
+					logging.LogError("Keeping current whitelist: %v", err)
+				}
+			})
+		}
 	}
 
 	return nil
 }
 
 func loadWhitelist(filePath string) error {
-	if whitelist != nil {
+	entries, err := readWhitelist(filePath)
+	if err != nil {
+		setWhitelist([]regexp.Regexp{})
+		return err
+	}
+	setWhitelist(entries)
+
+	if len(entries) > 0 {
+		logging.LogInfo("Loaded %d whitelist entries", len(entries))
+	}
+
+	return nil
+}
+
+// Reload reads the whitelist file again, and logs
+// which entries were added or removed. If the file
+// can't be read or has invalid entries, the current
+// whitelist is kept.
+func Reload() error {
+	if config.CONFIG.WhitelistPath == "" {
 		return nil
 	}
+	entries, err := readWhitelist(config.CONFIG.WhitelistPath)
+	if err != nil {
+		return err
+	}
+	previous := setWhitelist(entries)
 
+	added, removed := util.Diff(patterns(previous), patterns(entries))
+	if len(added) == 0 && len(removed) == 0 {
+		logging.LogInfo("Reloaded whitelist, %d entries unchanged", len(entries))
+		return nil
+	}
+	logging.LogInfo("Reloaded whitelist, %d entries, added %q, removed %q", len(entries), added, removed)
+	return nil
+}
+
+// setWhitelist replaces the whitelist,
+// returning the previous one.
+func setWhitelist(entries []regexp.Regexp) []regexp.Regexp {
+	whitelistMu.Lock()
+	defer whitelistMu.Unlock()
+	previous := whitelist
+	whitelist = entries
+	return previous
+}
+
+func patterns(entries []regexp.Regexp) []string {
+	return util.Map(entries, func(r regexp.Regexp) string {
+		return r.String()
+	})
+}
+
+func readWhitelist(filePath string) ([]regexp.Regexp, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		whitelist = []regexp.Regexp{}
-		return xerrors.NewError(fmt.Errorf("could not load whitelist file: %w", err), 0, "", true)
+		return nil, xerrors.NewError(fmt.Errorf("could not load whitelist file: %w", err), 0, "", true)
 	}
 
 	lines := strings.Split(string(data), "\n")
-	whitelist = []regexp.Regexp{}
+	entries := []regexp.Regexp{}
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -47,24 +112,26 @@ func loadWhitelist(filePath string) error {
 		}
 		regex, err := regexp.Compile(line)
 		if err != nil {
-			return xerrors.NewError(fmt.Errorf("could not compile whitelist line %s: %w", line, err), 0, "", true)
+			return nil, xerrors.NewError(fmt.Errorf("could not compile whitelist line %s: %w", line, err), 0, "", true)
 		}
-		whitelist = append(whitelist, *regex)
-	}
-
-	if len(whitelist) > 0 {
-		logging.LogInfo("Loaded %d whitelist entries", len(whitelist))
+		entries = append(entries, *regex)
 	}
 
-	return nil
+	return entries, nil
 }
 
 func Shutdown() error {
+	if stopWatching != nil {
+		stopWatching()
+		stopWatching = nil
+	}
 	return nil
 }
 
 // IsWhitelisted checks if the URL matches any whitelist pattern
 func IsWhitelisted(u string) bool {
+	whitelistMu.RLock()
+	defer whitelistMu.RUnlock()
 	for _, v := range whitelist {
 		if v.MatchString(u) {
 			return true
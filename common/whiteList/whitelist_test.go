@@ -85,3 +85,46 @@ func TestLoadWhitelist(t *testing.T) {
 		t.Error("IsWhitelisted(\"gemini://test.org/path/subpage.gmi\") = false, want true")
 	}
 }
+
+func TestReloadKeepsWhitelistOnError(t *testing.T) {
+	originalWhitelist := whitelist
+	originalWhitelistPath := config.CONFIG.WhitelistPath
+	defer func() {
+		whitelist = originalWhitelist
+		config.CONFIG.WhitelistPath = originalWhitelistPath
+	}()
+
+	tmpfile, err := os.CreateTemp("", "whitelist-reload-*.txt")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	config.CONFIG.WhitelistPath = tmpfile.Name()
+	if err := os.WriteFile(tmpfile.Name(), []byte("example\\.com\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write to temporary file: %v", err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	if err := os.WriteFile(tmpfile.Name(), []byte("other\\.org\n[invalid\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write to temporary file: %v", err)
+	}
+	if err := Reload(); err == nil {
+		t.Errorf("Expected Reload() to fail on an invalid regex")
+	}
+	if !IsWhitelisted("gemini://example.com/") || IsWhitelisted("gemini://other.org/") {
+		t.Errorf("Expected the previous whitelist to be kept")
+	}
+
+	if err := os.WriteFile(tmpfile.Name(), []byte("other\\.org\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write to temporary file: %v", err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if IsWhitelisted("gemini://example.com/") || !IsWhitelisted("gemini://other.org/") {
+		t.Errorf("Expected the new whitelist to replace the previous one")
+	}
+}
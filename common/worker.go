@@ -5,12 +5,20 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"gemini-grc/common/blackList"
 	"gemini-grc/common/contextlog"
 	commonErrors "gemini-grc/common/errors"
+	"gemini-grc/common/hostBudget"
+	"gemini-grc/common/inputQueries"
+	"gemini-grc/common/mimeFilter"
+	"gemini-grc/common/simhash"
 	"gemini-grc/common/snapshot"
+	"gemini-grc/common/spiderTrap"
+	"gemini-grc/common/takedown"
 	url2 "gemini-grc/common/url"
 	"gemini-grc/common/whiteList"
 	"gemini-grc/config"
@@ -19,6 +27,8 @@ import (
 	"gemini-grc/gemini"
 	"gemini-grc/gopher"
 	"gemini-grc/hostPool"
+	"gemini-grc/metrics"
+	"gemini-grc/protocol"
 	"gemini-grc/robotsMatch"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
@@ -39,6 +49,7 @@ func RunWorkerWithTx(workerID int, job string) {
 	ctx, cancel := contextutil.NewRequestContext(baseCtx, job, host, workerID)
 	ctx = contextutil.ContextWithComponent(ctx, "worker")
 	defer cancel() // Ensure the context is cancelled when we're done
+	defer trackJob(ctx)()
 	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Starting worker for URL %s", job)
 
 	// Create a new db transaction
@@ -47,6 +58,10 @@ func RunWorkerWithTx(workerID int, job string) {
 		FatalErrorsChan <- err
 		return
 	}
+	txStart := time.Now()
+	defer func() {
+		metrics.TxDuration.Observe(time.Since(txStart).Seconds())
+	}()
 
 	err = runWorker(ctx, tx, []string{job})
 	WorkerWG.Done()
@@ -121,15 +136,21 @@ func WorkOnUrl(ctx context.Context, tx *sqlx.Tx, url string) (err error) {
 		url = s.URL.Full
 	}
 
-	isGemini := url2.IsGeminiUrl(s.URL.String())
-	isGopher := url2.IsGopherURL(s.URL.String())
+	handler, ok := protocol.ForURL(s.URL.String())
+	if !ok {
+		return xerrors.NewSimpleError(fmt.Errorf("unsupported protocol, not processing URL: %s", s.URL.String()))
+	}
 
-	if !isGemini && !isGopher {
-		return xerrors.NewSimpleError(fmt.Errorf("not a Gopher or Gemini URL: %s", s.URL.String()))
+	if !handler.Enabled() {
+		return xerrors.NewSimpleError(fmt.Errorf("%s disabled, not processing URL: %s", handler.Scheme(), s.URL.String()))
 	}
 
-	if isGopher && !config.CONFIG.GopherEnable {
-		return xerrors.NewSimpleError(fmt.Errorf("gopher disabled, not processing Gopher URL: %s", s.URL.String()))
+	// Takedown rules apply even to whitelisted URLs.
+	// Nothing is saved, not even an error snapshot.
+	if rule, ok := takedown.Match(s.URL.String()); ok {
+		metrics.Errors.WithLabelValues("takedown").Inc()
+		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "URL matches takedown rule %d, skipping", rule.ID)
+		return removeURL(ctx, tx, url)
 	}
 
 	// Check if URL is whitelisted
@@ -140,22 +161,35 @@ func WorkOnUrl(ctx context.Context, tx *sqlx.Tx, url string) (err error) {
 
 	// Only check blacklist if URL is not whitelisted
 	if !isUrlWhitelisted && blackList.IsBlacklisted(s.URL.String()) {
+		metrics.Errors.WithLabelValues("blacklist").Inc()
 		s.Error = null.StringFrom(commonErrors.ErrBlacklistMatch.Error())
 		return saveSnapshotAndRemoveURL(ctx, tx, s)
 	}
 
-	// Only check robots.txt if URL is not whitelisted and is a Gemini URL
+	// Only check robots.txt if URL is not whitelisted
 	var robotMatch bool
-	if !isUrlWhitelisted && isGemini {
+	if !isUrlWhitelisted {
 		// If URL matches a robots.txt disallow line,
 		// add it as an error and remove url
-		robotMatch = robotsMatch.RobotMatch(ctx, s.URL.String())
+		robotMatch = robotsMatch.RobotMatch(ctx, tx, s.URL.String())
 		if robotMatch {
+			metrics.Errors.WithLabelValues("robots").Inc()
 			s.Error = null.StringFrom(commonErrors.ErrRobotsMatch.Error())
 			return saveSnapshotAndRemoveURL(ctx, tx, s)
 		}
 	}
 
+	depth, err := gemdb.Database.GetURLDepth(ctx, tx, url)
+	if err != nil {
+		return err
+	}
+
+	// Queued before the host's max depth was lowered.
+	if !hostBudget.For(s.Host).AllowsDepth(depth) {
+		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "URL at depth %d exceeds the max depth of its host, removing", depth)
+		return removeURL(ctx, tx, url)
+	}
+
 	err = hostPool.AddHostToHostPool(ctx, s.Host)
 	if err != nil {
 		return err
@@ -167,27 +201,69 @@ func WorkOnUrl(ctx context.Context, tx *sqlx.Tx, url string) (err error) {
 
 	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Visiting %s", s.URL.String())
 
-	// Use context-aware visits for both protocols
-	if isGopher {
-		s, err = gopher.VisitWithContext(ctx, s.URL.String())
-	} else {
-		s, err = gemini.Visit(ctx, s.URL.String())
-	}
-
+	s, err = visit(ctx, handler, s.URL.String())
 	if err != nil {
 		return err
 	}
 
-	// Handle Gemini redirection.
-	if isGemini &&
-		s.ResponseCode.ValueOrZero() >= 30 &&
-		s.ResponseCode.ValueOrZero() < 40 {
-		err = saveRedirectURL(ctx, tx, s)
+	return processSnapshot(ctx, tx, handler, s, newRedirectChain(s.URL.Full, depth))
+}
+
+// visit fetches a URL with the handler of its
+// protocol, extracts its links and records metrics.
+func visit(ctx context.Context, handler protocol.Handler, url string) (*snapshot.Snapshot, error) {
+	start := time.Now()
+	s, err := handler.Visit(ctx, url)
+	if err != nil || s == nil {
+		return s, err
+	}
+	if links := handler.Links(s); len(links) > 0 {
+		s.Links = null.ValueFrom(links)
+	}
+	scheme := handler.Scheme()
+	metrics.FetchDuration.WithLabelValues(scheme).Observe(time.Since(start).Seconds())
+
+	code := "none"
+	if s.ResponseCode.Valid {
+		code = strconv.FormatInt(s.ResponseCode.Int64, 10)
+	}
+	metrics.Requests.WithLabelValues(scheme, code).Inc()
+	metrics.BytesFetched.WithLabelValues(scheme).Add(float64(len(s.Data.V) + len(s.GemText.String)))
+
+	switch {
+	case s.Error.Valid && !s.ResponseCode.Valid && !s.Data.Valid && !s.GemText.Valid:
+		// Nothing came back
+		metrics.Errors.WithLabelValues("host").Inc()
+	case s.Error.Valid, scheme == "gemini" && s.ResponseCode.Int64 >= 40:
+		metrics.Errors.WithLabelValues(scheme).Inc()
+	}
+	return s, nil
+}
+
+// processSnapshot handles the redirects, input prompts
+// and links of a visited URL, and stores its snapshot.
+func processSnapshot(ctx context.Context, tx *sqlx.Tx, handler protocol.Handler, s *snapshot.Snapshot, chain *redirectChain) error {
+	if handler.IsRedirect(s) {
+		err := followRedirect(ctx, tx, handler, s, chain)
 		if err != nil {
 			return xerrors.NewSimpleError(fmt.Errorf("error while handling redirection: %s", err))
 		}
 	}
 
+	if prompt, sensitive, isInput := gemini.InputPrompt(s); isInput {
+		err := saveInputEndpoint(ctx, tx, s, prompt, sensitive)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasContent(s) && !mimeFilter.Allowed(s.MimeType.ValueOrZero()) {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Not storing content of type %s", s.MimeType.ValueOrZero())
+		s.SkipContent()
+	}
+
+	setSimHash(s)
+
 	// Check if we should skip a potentially
 	// identical snapshot with one from history
 	isIdentical, err := isContentIdentical(ctx, tx, s)
@@ -196,18 +272,23 @@ func WorkOnUrl(ctx context.Context, tx *sqlx.Tx, url string) (err error) {
 	}
 	if isIdentical {
 		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Content identical to existing snapshot, updating crawl timestamp")
-		// Update the last_crawled timestamp to track that we processed this URL
-		err = gemdb.Database.UpdateLastCrawled(ctx, tx, s.URL.String())
-		if err != nil {
-			return err
-		}
-		return removeURL(ctx, tx, s.URL.String())
+		metrics.Snapshots.WithLabelValues("identical").Inc()
+		return updateLastCrawledAndRemoveURL(ctx, tx, s)
+	}
+	isNearDuplicate, err := isContentNearDuplicate(ctx, tx, s)
+	if err != nil {
+		return err
+	}
+	if isNearDuplicate {
+		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Content nearly identical to existing snapshot, updating crawl timestamp")
+		metrics.Snapshots.WithLabelValues("near_duplicate").Inc()
+		return updateLastCrawledAndRemoveURL(ctx, tx, s)
 	}
 
 	// Process and store links since content has changed
 	if len(s.Links.ValueOrZero()) > 0 {
 		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Found %d links", len(s.Links.ValueOrZero()))
-		err = storeLinks(ctx, tx, s)
+		err = storeLinks(ctx, tx, s, chain.depth)
 		if err != nil {
 			return err
 		}
@@ -249,20 +330,109 @@ func isContentIdentical(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot)
 	return identical, nil
 }
 
+// hasContent reports if a snapshot has a body to store.
+func hasContent(s *snapshot.Snapshot) bool {
+	return s.Data.Valid || s.GemText.Valid
+}
+
+// setSimHash fingerprints the text content of a snapshot.
+func setSimHash(s *snapshot.Snapshot) {
+	var text string
+	switch {
+	case s.GemText.Valid:
+		text = s.GemText.String
+	case s.Data.Valid && strings.HasPrefix(s.MimeType.ValueOrZero(), "text/"):
+		text = string(s.Data.V)
+	default:
+		return
+	}
+	if fingerprint, ok := simhash.Fingerprint(text); ok {
+		s.SimHash = null.IntFrom(int64(fingerprint))
+	}
+}
+
+// isContentNearDuplicate checks if the content is similar
+// enough to the latest snapshot to not store it, like when
+// only a visit counter or a date changed.
+func isContentNearDuplicate(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) (bool, error) {
+	threshold := config.CONFIG.NearDuplicateSimilarity
+	if threshold <= 0 || !s.SimHash.Valid {
+		return false, nil
+	}
+	previous, err := gemdb.Database.GetLatestSimHash(ctx, tx, s.URL.String())
+	if err != nil {
+		return false, err
+	}
+	if !previous.Valid {
+		return false, nil
+	}
+	similarity := simhash.Similarity(uint64(s.SimHash.Int64), uint64(previous.Int64))
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Similarity to latest snapshot %.3f", similarity)
+	return similarity >= threshold, nil
+}
+
+func updateLastCrawledAndRemoveURL(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error {
+	// Update the last_crawled timestamp to track that we processed this URL
+	err := gemdb.Database.UpdateLastCrawled(ctx, tx, s.URL.String())
+	if err != nil {
+		return err
+	}
+	return removeURL(ctx, tx, s.URL.String())
+}
+
 // storeLinks checks and stores the snapshot links in the database.
-func storeLinks(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error {
+// depth is the link depth of the snapshot.
+func storeLinks(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot, depth int) error {
+	hostURLs := make(map[string]int)
 	if s.Links.Valid { //nolint:nestif
 		for _, link := range s.Links.ValueOrZero() {
+			link, err := canonicalLink(ctx, tx, link)
+			if err != nil {
+				return err
+			}
 			if shouldPersistURL(&link) {
-				visited, err := haveWeVisitedURL(ctx, tx, link.Full)
+				queued, visited, err := urlStatus(ctx, tx, link.Full)
 				if err != nil {
 					return err
 				}
 				if !visited {
-					err := gemdb.Database.InsertURL(ctx, tx, link.Full)
+					linkDepth := hostBudget.LinkDepth(depth, link.Path)
+					withinBudget, err := isWithinHostBudget(ctx, tx, link, linkDepth, hostURLs)
+					if err != nil {
+						return err
+					}
+					if !withinBudget {
+						continue
+					}
+					// Queued links passed the spider
+					// trap checks when first enqueued.
+					if !queued {
+						quarantined, err := quarantineSpiderTrap(ctx, tx, s, link)
+						if err != nil {
+							return err
+						}
+						if quarantined {
+							continue
+						}
+					}
+					if query, isSearch := gopher.SearchQuery(&link); isSearch {
+						enqueued, err := storeGopherSearch(ctx, tx, s, link, linkDepth, query)
+						if err != nil {
+							return err
+						}
+						if enqueued && !queued {
+							recordSpiderTrapCount(link)
+						}
+						continue
+					}
+					err = gemdb.Database.InsertURLWithDepth(ctx, tx, link.Full, linkDepth)
 					if err != nil {
 						return err
 					}
+					if !queued {
+						recordSpiderTrapCount(link)
+					}
+					hostURLs[link.Hostname]++
 				} else {
 					contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Link already persisted: %s", link.Full)
 				}
@@ -272,6 +442,141 @@ func storeLinks(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error {
 	return nil
 }
 
+// isWithinHostBudget checks a link against the budget
+// of its host. hostURLs caches the URL count of each
+// host while storing the links of a page.
+func isWithinHostBudget(ctx context.Context, tx *sqlx.Tx, link url2.URL, linkDepth int, hostURLs map[string]int) (bool, error) {
+	budget := hostBudget.For(link.Hostname)
+	if !budget.AllowsDepth(linkDepth) {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Link %s at depth %d exceeds max depth %d", link.Full, linkDepth, budget.MaxDepth)
+		return false, nil
+	}
+	if budget.MaxURLs == 0 {
+		return true, nil
+	}
+	count, ok := hostURLs[link.Hostname]
+	if !ok {
+		var err error
+		count, err = gemdb.Database.CountHostURLs(ctx, tx, link.Hostname)
+		if err != nil {
+			return false, err
+		}
+		hostURLs[link.Hostname] = count
+	}
+	if count >= budget.MaxURLs {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Host %s reached its budget of %d URLs, skipping %s", link.Hostname, budget.MaxURLs, link.Full)
+		return false, nil
+	}
+	return true, nil
+}
+
+// quarantineSpiderTrap keeps a link out of the queue
+// if it looks like a spider trap, and records it for
+// review. Whitelisted URLs are never quarantined.
+func quarantineSpiderTrap(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot, link url2.URL) (bool, error) {
+	if whiteList.IsWhitelisted(link.String()) {
+		return false, nil
+	}
+	reason, isTrap := spiderTrap.Check(&link)
+	if !isTrap {
+		return false, nil
+	}
+	contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Quarantining suspected spider trap %s: %s", link.Full, reason)
+	err := gemdb.Database.QuarantineURL(ctx, tx, &gemdb.QuarantinedURL{
+		URL:       link.Full,
+		Host:      link.Hostname,
+		SourceURL: s.URL.Full,
+		Reason:    reason,
+		Seen:      time.Now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordSpiderTrapCount counts a newly enqueued link
+// towards the spider trap limits of its host, unless
+// it's whitelisted and so never checked.
+func recordSpiderTrapCount(link url2.URL) {
+	if !whiteList.IsWhitelisted(link.String()) {
+		spiderTrap.Record(&link)
+	}
+}
+
+// storeGopherSearch enqueues a Gopher search and
+// records the menu it came from, unless the host
+// had enough searches within the search window.
+func storeGopherSearch(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot, link url2.URL, depth int, query string) (bool, error) {
+	since := time.Now().Add(-time.Duration(config.CONFIG.GopherSearchWindow) * time.Hour)
+	count, err := gemdb.Database.CountGopherSearches(ctx, tx, link.Hostname, since)
+	if err != nil {
+		return false, err
+	}
+	if count >= config.CONFIG.GopherSearchMax {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Gopher search limit reached for %s, skipping %s", link.Hostname, link.Full)
+		return false, nil
+	}
+	err = gemdb.Database.InsertURLWithDepth(ctx, tx, link.Full, depth)
+	if err != nil {
+		return false, err
+	}
+	err = gemdb.Database.InsertGopherSearch(ctx, tx, &gemdb.GopherSearch{
+		URL:       link.Full,
+		Host:      link.Hostname,
+		MenuURL:   s.URL.Full,
+		Query:     query,
+		Timestamp: time.Now(),
+	})
+	return err == nil, err
+}
+
+// saveInputEndpoint records the endpoint of a URL that
+// asks for input, and enqueues the canned queries we have
+// for it. Sensitive input is never submitted, and URLs that
+// already carry a query, e.g. a canned query answered with
+// another prompt, don't get more queries.
+func saveInputEndpoint(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot, prompt string, sensitive bool) error {
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Input endpoint with prompt: %s", prompt)
+	endpoint := gemini.InputEndpoint(s.URL.Full)
+	err := gemdb.Database.SaveInputEndpoint(ctx, tx, &gemdb.InputEndpoint{
+		URL:       endpoint,
+		Host:      s.Host,
+		Prompt:    prompt,
+		Sensitive: sensitive,
+		Seen:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if sensitive || strings.Contains(s.URL.Full, "?") {
+		return nil
+	}
+
+	for _, query := range inputQueries.QueriesFor(endpoint) {
+		queryURL, err := url2.ParseURL(gemini.InputURL(endpoint, query), "", true)
+		if err != nil {
+			return err
+		}
+		if !shouldPersistURL(queryURL) {
+			continue
+		}
+		visited, err := haveWeVisitedURL(ctx, tx, queryURL.Full)
+		if err != nil {
+			return err
+		}
+		if visited {
+			continue
+		}
+		err = gemdb.Database.InsertURL(ctx, tx, queryURL.Full)
+		if err != nil {
+			return err
+		}
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Enqueued input query %s", queryURL.Full)
+	}
+	return nil
+}
+
 func removeURL(ctx context.Context, tx *sqlx.Tx, url string) error {
 	return gemdb.Database.DeleteURL(ctx, tx, url)
 }
@@ -286,6 +591,12 @@ func saveSnapshotAndRemoveURL(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snap
 		if err != nil {
 			return err
 		}
+		metrics.Snapshots.WithLabelValues("saved").Inc()
+		if s.SkippedContent {
+			metrics.SkippedContent.WithLabelValues(s.MimeType.ValueOrZero()).Inc()
+		} else if hasContent(s) {
+			metrics.StoredBytes.WithLabelValues(s.MimeType.ValueOrZero()).Add(float64(len(s.Data.V) + len(s.GemText.String)))
+		}
 		contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "%2d", s.ResponseCode.ValueOrZero())
 		return removeURL(ctx, tx, s.URL.String())
 	} else {
@@ -299,83 +610,50 @@ func saveSnapshotAndRemoveURL(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snap
 }
 
 // shouldPersistURL returns true given URL is a
-// non-blacklisted Gemini or Gopher URL.
+// non-blacklisted URL of an enabled protocol,
+// not covered by a takedown rule.
 func shouldPersistURL(u *url2.URL) bool {
 	if blackList.IsBlacklisted(u.String()) {
 		return false
 	}
-	if config.CONFIG.GopherEnable && url2.IsGopherURL(u.String()) {
-		return true
+	if _, ok := takedown.Match(u.String()); ok {
+		return false
 	}
-	return url2.IsGeminiUrl(u.String())
+	return protocol.IsEnabled(u.String())
 }
 
 func haveWeVisitedURL(ctx context.Context, tx *sqlx.Tx, u string) (bool, error) {
-	var result []bool
+	_, visited, err := urlStatus(ctx, tx, u)
+	return visited, err
+}
 
+// urlStatus reports if a URL is in the queue, and if
+// it was visited recently enough to skip it. Queued
+// URLs don't count as visited.
+func urlStatus(ctx context.Context, tx *sqlx.Tx, u string) (queued bool, visited bool, err error) {
 	// Check if the context is cancelled
 	if err := ctx.Err(); err != nil {
-		return false, xerrors.NewSimpleError(err)
+		return false, false, xerrors.NewSimpleError(err)
 	}
 
 	// Check the urls table which holds the crawl queue.
-	err := tx.SelectContext(ctx, &result, `SELECT TRUE FROM urls WHERE url=$1`, u)
+	queued, err = gemdb.Database.IsURLQueued(ctx, tx, u)
 	if err != nil {
-		return false, xerrors.NewError(fmt.Errorf("database error: %w", err), 0, "", true)
+		return false, false, err
 	}
-	if len(result) > 0 {
-		return false, nil
+	if queued {
+		return true, false, nil
 	}
 
 	// If we're skipping URLs based on recent updates, check if this URL has been
 	// crawled within the specified number of days
 	if config.CONFIG.SkipIfUpdatedDays > 0 {
-		var recentSnapshots []bool
 		cutoffDate := time.Now().AddDate(0, 0, -config.CONFIG.SkipIfUpdatedDays)
-
-		// Check if the context is cancelled
-		if err := ctx.Err(); err != nil {
-			return false, err
-		}
-
-		err = tx.SelectContext(ctx, &recentSnapshots, `
-			SELECT TRUE FROM snapshots
-			WHERE snapshots.url=$1
-			AND timestamp > $2
-			LIMIT 1`, u, cutoffDate)
-		if err != nil {
-			return false, xerrors.NewError(fmt.Errorf("database error checking recent snapshots: %w", err), 0, "", true)
-		}
-
-		if len(recentSnapshots) > 0 {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
-
-func saveRedirectURL(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error {
-	newURL, err := url2.ExtractRedirectTargetFromHeader(s.URL, s.Header.ValueOrZero())
-	if err != nil {
-		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Failed to extract redirect target: %v", err)
-		return err
+		visited, err = gemdb.Database.HasSnapshotSince(ctx, tx, u, cutoffDate)
+		return false, visited, err
 	}
-	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Page redirects to %s", newURL)
 
-	haveWeVisited, err := haveWeVisitedURL(ctx, tx, newURL.String())
-	if err != nil {
-		return err
-	}
-
-	if shouldPersistURL(newURL) && !haveWeVisited {
-		err = gemdb.Database.InsertURL(ctx, tx, newURL.Full)
-		if err != nil {
-			return err
-		}
-		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Saved redirection URL %s", newURL.String())
-	}
-	return nil
+	return false, false, nil
 }
 
 //func GetSnapshotFromURL(tx *sqlx.Tx, url string) ([]snapshot.Snapshot, error) {
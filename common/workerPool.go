@@ -0,0 +1,164 @@
+package common
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gemini-grc/contextutil"
+	"git.antanst.com/antanst/logging"
+)
+
+// The worker pool runs the jobs the scheduler sends.
+// Workers can be paused, which lets them finish their
+// current job and wait before taking another one, and
+// their number can change while crawling.
+
+type workerPool struct {
+	jobs    <-chan string
+	stops   []chan struct{} // One per running worker
+	nextID  int
+	resumed chan struct{} // Closed while not paused
+	paused  bool
+	mu      sync.Mutex
+}
+
+var pool = &workerPool{resumed: closedChan()} //nolint:gochecknoglobals
+
+func closedChan() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
+
+// StartWorkers starts a number of workers taking jobs from a channel.
+func StartWorkers(jobs <-chan string, total int) {
+	pool.mu.Lock()
+	pool.jobs = jobs
+	pool.mu.Unlock()
+	SetWorkerCount(total)
+}
+
+// SetWorkerCount starts or stops workers until
+// there are total. Stopped workers finish
+// their current job first.
+func SetWorkerCount(total int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for len(pool.stops) < total {
+		stop := make(chan struct{})
+		pool.stops = append(pool.stops, stop)
+		go runWorkerLoop(pool.nextID, stop)
+		pool.nextID++
+	}
+	for len(pool.stops) > total && total >= 0 {
+		last := len(pool.stops) - 1
+		close(pool.stops[last])
+		pool.stops = pool.stops[:last]
+	}
+	logging.LogInfo("Running %d workers", len(pool.stops))
+}
+
+// WorkerCount returns the number of running workers.
+func WorkerCount() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return len(pool.stops)
+}
+
+// PauseWorkers stops workers from taking new jobs.
+func PauseWorkers() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if !pool.paused {
+		pool.paused = true
+		pool.resumed = make(chan struct{})
+		logging.LogInfo("Workers paused")
+	}
+}
+
+// ResumeWorkers lets paused workers take jobs again.
+func ResumeWorkers() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.paused {
+		pool.paused = false
+		close(pool.resumed)
+		logging.LogInfo("Workers resumed")
+	}
+}
+
+// WorkersPaused reports if the workers are paused.
+func WorkersPaused() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.paused
+}
+
+func runWorkerLoop(id int, stop <-chan struct{}) {
+	for {
+		pool.mu.Lock()
+		resumed := pool.resumed
+		jobs := pool.jobs
+		pool.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-resumed:
+		}
+
+		select {
+		case <-stop:
+			return
+		case job := <-jobs:
+			RunWorkerWithTx(id, job)
+		}
+	}
+}
+
+// Job is a URL a worker is visiting.
+type Job struct {
+	URL       string        `json:"url"`
+	WorkerID  int           `json:"worker_id"`
+	RequestID string        `json:"request_id"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+}
+
+var (
+	inFlight   = make(map[string]context.Context) //nolint:gochecknoglobals
+	inFlightMu sync.Mutex                         //nolint:gochecknoglobals
+)
+
+func trackJob(ctx context.Context) func() {
+	requestID := contextutil.GetRequestIDFromContext(ctx)
+	inFlightMu.Lock()
+	inFlight[requestID] = ctx
+	inFlightMu.Unlock()
+	return func() {
+		inFlightMu.Lock()
+		delete(inFlight, requestID)
+		inFlightMu.Unlock()
+	}
+}
+
+// InFlightJobs returns the jobs workers are
+// busy with, the longest running first.
+func InFlightJobs() []Job {
+	inFlightMu.Lock()
+	jobs := make([]Job, 0, len(inFlight))
+	for requestID, ctx := range inFlight {
+		jobs = append(jobs, Job{
+			URL:       contextutil.GetURLFromContext(ctx),
+			WorkerID:  contextutil.GetWorkerIDFromContext(ctx),
+			RequestID: requestID,
+			Elapsed:   time.Since(contextutil.GetStartTimeFromContext(ctx)),
+		})
+	}
+	inFlightMu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Elapsed > jobs[j].Elapsed
+	})
+	return jobs
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetWorkerCount(t *testing.T) {
+	defer SetWorkerCount(0)
+
+	tests := []struct {
+		name  string
+		total int
+	}{
+		{"start workers", 3},
+		{"stop some", 1},
+		{"negative is ignored", -1},
+		{"start again", 2},
+		{"stop all", 0},
+	}
+	expected := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetWorkerCount(tt.total)
+			if tt.total >= 0 {
+				expected = tt.total
+			}
+			if got := WorkerCount(); got != expected {
+				t.Errorf("WorkerCount() = %d, want %d", got, expected)
+			}
+		})
+	}
+}
+
+func TestPauseWorkers(t *testing.T) {
+	defer ResumeWorkers()
+
+	PauseWorkers()
+	PauseWorkers()
+	if !WorkersPaused() {
+		t.Fatalf("Expected workers to be paused")
+	}
+	select {
+	case <-pool.resumed:
+		t.Errorf("Paused workers should not take jobs")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ResumeWorkers()
+	ResumeWorkers()
+	if WorkersPaused() {
+		t.Fatalf("Expected workers to be resumed")
+	}
+	select {
+	case <-pool.resumed:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("Resumed workers should take jobs")
+	}
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"testing"
+
+	"gemini-grc/common/snapshot"
+	"github.com/guregu/null/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSimHash(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		mimeType string
+		gemText  null.String
+		data     null.Value[[]byte]
+		want     bool
+	}{
+		{"gemtext", "text/gemini", null.StringFrom("# Hello world"), null.Value[[]byte]{}, true},
+		{"plain text", "text/plain", null.String{}, null.ValueFrom([]byte("Hello world")), true},
+		{"image", "image/png", null.String{}, null.ValueFrom([]byte("PNG data")), false},
+		{"no words", "text/plain", null.String{}, null.ValueFrom([]byte("  ")), false},
+		{"error", "", null.String{}, null.Value[[]byte]{}, false},
+	}
+	for _, tt := range tests {
+		s, err := snapshot.SnapshotFromURL("gemini://example.com/", true)
+		assert.NoError(t, err)
+		s.MimeType = null.StringFrom(tt.mimeType)
+		s.GemText = tt.gemText
+		s.Data = tt.data
+		setSimHash(s)
+		assert.Equal(t, tt.want, s.SimHash.Valid, tt.name)
+	}
+}
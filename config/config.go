@@ -5,65 +5,178 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 )
 
-// Config holds the application configuration loaded from environment variables.
+// Config holds the application configuration, see Load.
 type Config struct {
-	PgURL             string
-	LogLevel          slog.Level // Logging level (debug, info, warn, error)
-	MaxResponseSize   int        // Maximum size of response in bytes
-	MaxDbConnections  int        // Maximum number of database connections.
-	NumOfWorkers      int        // Number of concurrent workers
-	ResponseTimeout   int        // Timeout for responses in seconds
-	BlacklistPath     string     // File that has blacklisted strings of "host:port"
-	WhitelistPath     string     // File with URLs that should always be crawled regardless of blacklist
-	DryRun            bool       // If false, don't write to disk
-	GopherEnable      bool       // Enable Gopher crawling
-	SeedUrlPath       string     // Add URLs from file to queue
-	SkipIfUpdatedDays int        // Skip re-crawling URLs updated within this many days (0 to disable)
+	PgURL                   string
+	DbURL                   string     // Database URL, overrides PgURL
+	LogLevel                slog.Level // Logging level (debug, info, warn, error)
+	MaxResponseSize         int        // Maximum size of response in bytes
+	MaxDbConnections        int        // Maximum number of database connections.
+	NumOfWorkers            int        // Number of concurrent workers
+	ResponseTimeout         int        // Timeout for responses in seconds
+	BlacklistPath           string     // File that has blacklisted strings of "host:port"
+	WhitelistPath           string     // File with URLs that should always be crawled regardless of blacklist
+	ListsWatchInterval      int        // Seconds between checks of the blacklist and whitelist files for changes (0 to disable)
+	InputQueriesPath        string     // File with Gemini input endpoints and the queries to submit to them
+	HostURLsPerCycle        int        // Maximum URLs per host each scheduler run (0 for no limit)
+	HostMaxURLs             int        // Maximum URLs per host queued or archived (0 for no limit)
+	MaxDepth                int        // Maximum link depth from a seed or capsule root (0 for no limit)
+	DryRun                  bool       // Crawl with the memory database, printing snapshots instead of saving them
+	DryRunLimit             int        // Dry run: URLs to crawl before exiting (0 for no limit)
+	GopherEnable            bool       // Enable Gopher crawling
+	SpartanEnable           bool       // Enable Spartan crawling
+	NexEnable               bool       // Enable Nex crawling
+	FingerEnable            bool       // Enable crawling of finger plans
+	GopherSearchQueries     []string   // Sample queries to run against Gopher search servers (type 7)
+	GopherSearchMax         int        // Maximum number of searches per Gopher host within GopherSearchWindow
+	GopherSearchWindow      int        // Hours over which GopherSearchMax is counted
+	MaxRedirects            int        // Maximum number of redirects a worker follows for one URL
+	NearDuplicateSimilarity float64    // SimHash similarity above which content counts as unchanged (0 to disable)
+	TrapMaxPathDepth        int        // Spider trap: maximum number of path segments
+	TrapMaxRepeatedSegments int        // Spider trap: maximum times a path segment can repeat
+	TrapMaxQueryVariants    int        // Spider trap: maximum different queries per path and hour
+	TrapMaxHostURLsPerHour  int        // Spider trap: maximum new URLs per host and hour
+	SeedUrlPath             string     // Add URLs from file to queue
+	SkipIfUpdatedDays       int        // Skip re-crawling URLs updated within this many days (0 to disable)
+	CrawlerMode             string     // What the crawl is for (archiver, indexer, researcher), selects the robots.txt virtual user agent
+	RobotsCacheTTLHours     int        // How long fetched robots.txt files are used before refreshing them
+	MetricsAddr             string     // Address to serve Prometheus metrics on, e.g. localhost:9090 (empty to disable)
+	AdminAddr               string     // Loopback address or unix:/path socket to serve the admin API on (empty to disable)
+	RequestInterval         float64    // Minimum seconds between requests to the same host
+	StoreMimeTypes          []string   // MIME types to store the content of, with wildcards (empty for all)
+	SkipMimeTypes           []string   // MIME types to store header-only snapshots of, overriding StoreMimeTypes
+
+	Hosts    []HostConfig // Per-host overrides, from the config file
+	Policies []Policy     // Response limits by host and MIME type, from the config file
 }
 
 var CONFIG Config //nolint:gochecknoglobals
 
-// Initialize loads and validates configuration from environment variables
+// Initialize loads the configuration from the command line,
+// and exits if it's invalid.
 func Initialize() *Config {
+	config, err := Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(-1)
+	}
+	return config
+}
+
+// Load parses and validates the configuration. Settings
+// come from, in increasing order of precedence: flag
+// defaults, the config file, GEMINI_GRC_* environment
+// variables and command-line flags.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
 	config := &Config{}
 
-	loglevel := flag.String("log-level", "info", "Logging level (debug, info, warn, error)")
-	pgURL := flag.String("pgurl", "", "Postgres URL")
-	dryRun := flag.Bool("dry-run", false, "Dry run mode")
-	gopherEnable := flag.Bool("gopher", false, "Enable crawling of Gopher holes")
-	maxDbConnections := flag.Int("max-db-connections", 100, "Maximum number of database connections")
-	numOfWorkers := flag.Int("workers", 1, "Number of concurrent workers")
-	maxResponseSize := flag.Int("max-response-size", 1024*1024, "Maximum size of response in bytes")
-	responseTimeout := flag.Int("response-timeout", 10, "Timeout for network responses in seconds")
-	blacklistPath := flag.String("blacklist-path", "", "File that has blacklist regexes")
-	skipIfUpdatedDays := flag.Int("skip-if-updated-days", 60, "Skip re-crawling URLs updated within this many days (0 to disable)")
-	whitelistPath := flag.String("whitelist-path", "", "File with URLs that should always be crawled regardless of blacklist")
-	seedUrlPath := flag.String("seed-url-path", "", "File with seed URLs that should be added to the queue immediatelly")
-
-	flag.Parse()
+	configPath := fs.String("config", "", "YAML config file, see config.example.yaml")
+	loglevel := fs.String("log-level", "info", "Logging level (debug, info, warn, error)")
+	pgURL := fs.String("pgurl", "", "Postgres URL")
+	dbURL := fs.String("db-url", "", "Database URL, postgres://... or sqlite://path/to/file.db (overrides -pgurl)")
+	dryRun := fs.Bool("dry-run", false, "Crawl without a database, printing saved snapshots as JSON lines")
+	dryRunLimit := fs.Int("dry-run-limit", 100, "URLs to crawl in dry run mode before exiting (0 for no limit)")
+	gopherEnable := fs.Bool("gopher", false, "Enable crawling of Gopher holes")
+	spartanEnable := fs.Bool("spartan", false, "Enable crawling of Spartan capsules")
+	nexEnable := fs.Bool("nex", false, "Enable crawling of Nex sites")
+	fingerEnable := fs.Bool("finger", false, "Enable crawling of finger plans")
+	gopherSearchQueries := fs.String("gopher-search-queries", "", "Comma separated sample queries to run against Gopher search servers")
+	gopherSearchMax := fs.Int("gopher-search-max-per-host", 10, "Maximum number of searches to run per Gopher host within -gopher-search-window-hours")
+	gopherSearchWindow := fs.Int("gopher-search-window-hours", 24, "Hours over which -gopher-search-max-per-host is counted")
+	maxRedirects := fs.Int("max-redirects", 5, "Maximum number of same-host redirects to follow when visiting a URL")
+	trapMaxPathDepth := fs.Int("trap-max-path-depth", 20, "Quarantine URLs with more path segments than this (0 to disable)")
+	trapMaxRepeatedSegments := fs.Int("trap-max-repeated-segments", 3, "Quarantine URLs where a path segment repeats more than this (0 to disable)")
+	trapMaxQueryVariants := fs.Int("trap-max-query-variants", 100, "Quarantine URLs of a path seen with more different queries than this within an hour (0 to disable)")
+	trapMaxHostURLsPerHour := fs.Int("trap-max-host-urls-per-hour", 5000, "Quarantine new URLs of a host that had more than this enqueued within an hour (0 to disable)")
+	hostURLsPerCycle := fs.Int("host-urls-per-cycle", 0, "Maximum number of URLs per host each scheduler run (0 for no limit)")
+	hostMaxURLs := fs.Int("host-max-urls", 0, "Maximum number of URLs per host, queued or archived (0 for no limit)")
+	maxDepth := fs.Int("max-depth", 0, "Maximum link depth from a seed or capsule root (0 for no limit)")
+	nearDuplicateSimilarity := fs.Float64("near-duplicate-similarity", 0, "SimHash similarity (0-1) above which text content counts as unchanged (0 to disable)")
+	metricsAddr := fs.String("metrics-addr", "", "Address to serve Prometheus metrics on /metrics, e.g. localhost:9090 (empty to disable)")
+	adminAddr := fs.String("admin-addr", "", "Loopback address, e.g. localhost:9091, or unix:/path/to.sock to serve the admin API on (empty to disable)")
+	maxDbConnections := fs.Int("max-db-connections", 100, "Maximum number of database connections")
+	numOfWorkers := fs.Int("workers", 1, "Number of concurrent workers")
+	maxResponseSize := fs.Int("max-response-size", 1024*1024, "Maximum size of response in bytes")
+	responseTimeout := fs.Int("response-timeout", 10, "Timeout for network responses in seconds")
+	blacklistPath := fs.String("blacklist-path", "", "File that has blacklist regexes")
+	skipIfUpdatedDays := fs.Int("skip-if-updated-days", 60, "Skip re-crawling URLs updated within this many days (0 to disable)")
+	whitelistPath := fs.String("whitelist-path", "", "File with URLs that should always be crawled regardless of blacklist")
+	listsWatchInterval := fs.Int("lists-watch-interval", 30, "Seconds between checks of the blacklist and whitelist files for changes, which are then reloaded (0 to disable)")
+	inputQueriesPath := fs.String("input-queries-path", "", "File with Gemini input endpoints and canned queries to submit to them")
+	seedUrlPath := fs.String("seed-url-path", "", "File with seed URLs that should be added to the queue immediatelly")
+	robotsCacheTTLHours := fs.Int("robots-ttl-hours", 24, "How many hours fetched robots.txt files are cached before refreshing them")
+	crawlerMode := fs.String("crawler-mode", "archiver", "What the crawl is for, selects the robots.txt virtual user agent (archiver, indexer, researcher)")
+
+	requestInterval := fs.Float64("request-interval", 0, "Minimum seconds between requests to the same host")
+	storeMimeTypes := fs.String("store-mime-types", "", "Comma separated MIME types to store the content of, with wildcards like image/* (empty for all)")
+	skipMimeTypes := fs.String("skip-mime-types", "", "Comma separated MIME types to store header-only snapshots of, overriding -store-mime-types")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	file, err := applyLayers(fs, *configPath, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
 
 	config.PgURL = *pgURL
+	config.DbURL = *dbURL
 	config.DryRun = *dryRun
+	config.DryRunLimit = *dryRunLimit
 	config.GopherEnable = *gopherEnable
+	config.SpartanEnable = *spartanEnable
+	config.NexEnable = *nexEnable
+	config.FingerEnable = *fingerEnable
+	config.GopherSearchQueries = ParseList(*gopherSearchQueries)
+	config.GopherSearchMax = *gopherSearchMax
+	config.GopherSearchWindow = *gopherSearchWindow
+	config.MaxRedirects = *maxRedirects
+	config.TrapMaxPathDepth = *trapMaxPathDepth
+	config.TrapMaxRepeatedSegments = *trapMaxRepeatedSegments
+	config.TrapMaxQueryVariants = *trapMaxQueryVariants
+	config.TrapMaxHostURLsPerHour = *trapMaxHostURLsPerHour
+	config.NearDuplicateSimilarity = *nearDuplicateSimilarity
 	config.NumOfWorkers = *numOfWorkers
 	config.MaxResponseSize = *maxResponseSize
 	config.ResponseTimeout = *responseTimeout
 	config.BlacklistPath = *blacklistPath
 	config.WhitelistPath = *whitelistPath
+	config.ListsWatchInterval = *listsWatchInterval
+	config.InputQueriesPath = *inputQueriesPath
 	config.SeedUrlPath = *seedUrlPath
+	config.HostURLsPerCycle = *hostURLsPerCycle
+	config.HostMaxURLs = *hostMaxURLs
+	config.MaxDepth = *maxDepth
 	config.MaxDbConnections = *maxDbConnections
 	config.SkipIfUpdatedDays = *skipIfUpdatedDays
+	config.RobotsCacheTTLHours = *robotsCacheTTLHours
+	config.MetricsAddr = *metricsAddr
+	config.AdminAddr = *adminAddr
+	config.RequestInterval = *requestInterval
+	config.StoreMimeTypes = ParseList(*storeMimeTypes)
+	config.SkipMimeTypes = ParseList(*skipMimeTypes)
+	config.Hosts = file.Hosts
+	config.Policies = file.Policies
 
 	level, err := ParseSlogLevel(*loglevel)
 	if err != nil {
-		_, _ = fmt.Fprint(os.Stderr, err.Error())
-		os.Exit(-1)
+		return nil, err
 	}
 	config.LogLevel = level
 
-	return config
+	mode, err := ParseCrawlerMode(*crawlerMode)
+	if err != nil {
+		return nil, err
+	}
+	config.CrawlerMode = mode
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 // ParseSlogLevel converts a string level to slog.Level
@@ -82,6 +195,39 @@ func ParseSlogLevel(levelStr string) (slog.Level, error) {
 	}
 }
 
+// ParseCrawlerMode validates the crawler mode, which
+// is one of the Gemini robots.txt virtual user agents.
+func ParseCrawlerMode(mode string) (string, error) {
+	switch mode {
+	case "archiver", "indexer", "researcher":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid crawler mode: %s", mode)
+	}
+}
+
+// ParseList splits a comma separated list,
+// dropping empty items.
+func ParseList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// DatabaseURL returns the URL of the database to use:
+// -db-url, or -pgurl from before SQLite support.
+func (c *Config) DatabaseURL() string {
+	if c.DbURL != "" {
+		return c.DbURL
+	}
+	return c.PgURL
+}
+
 // Convert method for backward compatibility with existing codebase
 // This can be removed once all references to Convert() are updated
 func (c *Config) Convert() *Config {
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(args []string, env map[string]string) (*Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	return Load(fs, args, lookupEnv)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+pgurl: postgres://file
+workers: 4
+response-timeout: 20
+gopher-search-queries:
+  - foo
+  - bar
+`)
+
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantWorkers int
+		wantPgURL   string
+	}{
+		{
+			name:        "Defaults",
+			args:        []string{"-pgurl", "postgres://flag"},
+			wantWorkers: 1,
+			wantPgURL:   "postgres://flag",
+		},
+		{
+			name:        "File over defaults",
+			args:        []string{"-config", path},
+			wantWorkers: 4,
+			wantPgURL:   "postgres://file",
+		},
+		{
+			name:        "Env over file",
+			args:        []string{"-config", path},
+			env:         map[string]string{"GEMINI_GRC_WORKERS": "8"},
+			wantWorkers: 8,
+			wantPgURL:   "postgres://file",
+		},
+		{
+			name:        "Flags over env",
+			args:        []string{"-config", path, "-workers", "16"},
+			env:         map[string]string{"GEMINI_GRC_WORKERS": "8", "GEMINI_GRC_PGURL": "postgres://env"},
+			wantWorkers: 16,
+			wantPgURL:   "postgres://env",
+		},
+		{
+			name:        "Config file from env",
+			env:         map[string]string{"GEMINI_GRC_CONFIG": path},
+			wantWorkers: 4,
+			wantPgURL:   "postgres://file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := load(tt.args, tt.env)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if c.NumOfWorkers != tt.wantWorkers {
+				t.Errorf("NumOfWorkers = %d, want %d", c.NumOfWorkers, tt.wantWorkers)
+			}
+			if c.PgURL != tt.wantPgURL {
+				t.Errorf("PgURL = %q, want %q", c.PgURL, tt.wantPgURL)
+			}
+		})
+	}
+
+	c, err := load([]string{"-config", path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ResponseTimeout != 20 {
+		t.Errorf("ResponseTimeout = %d, want 20", c.ResponseTimeout)
+	}
+	if strings.Join(c.GopherSearchQueries, ",") != "foo,bar" {
+		t.Errorf("GopherSearchQueries = %v, want [foo bar]", c.GopherSearchQueries)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "Unknown setting",
+			file:    "pgurl: postgres://file\nworker: 4\n",
+			wantErr: "unknown setting worker",
+		},
+		{
+			name:    "Unknown host setting",
+			file:    "pgurl: postgres://file\nhosts:\n  - host: example.org\n    timeout: 5\n",
+			wantErr: "field timeout not found",
+		},
+		{
+			name:    "Invalid value",
+			file:    "pgurl: postgres://file\nworkers: many\n",
+			wantErr: "invalid workers",
+		},
+		{
+			name:    "Invalid env value",
+			env:     map[string]string{"GEMINI_GRC_WORKERS": "many"},
+			args:    []string{"-pgurl", "postgres://flag"},
+			wantErr: "invalid GEMINI_GRC_WORKERS",
+		},
+		{
+			name:    "Missing pgurl",
+			wantErr: "pgurl is required",
+		},
+		{
+			name:    "Invalid values",
+			args:    []string{"-pgurl", "postgres://flag", "-workers", "0", "-response-timeout", "600"},
+			wantErr: "workers must be more than 0",
+		},
+		{
+			name:    "Policy without limits",
+			file:    "pgurl: postgres://file\npolicies:\n  - mime: image/*\n",
+			wantErr: "sets neither",
+		},
+		{
+			name:    "Invalid host pattern",
+			file:    "pgurl: postgres://file\nhosts:\n  - host: \"[example.org\"\n",
+			wantErr: "invalid host pattern",
+		},
+		{
+			name:    "Negative host budget",
+			file:    "pgurl: postgres://file\nhosts:\n  - host: example.org\n    host-max-urls: -1\n",
+			wantErr: "host-max-urls can't be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfigFile(t, tt.file))
+			}
+			_, err := load(args, tt.env)
+			if err == nil {
+				t.Fatalf("Load() error = nil, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabaseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"Pgurl", []string{"-pgurl", "postgres://flag"}, nil, "postgres://flag"},
+		{"Db-url over pgurl", []string{"-pgurl", "postgres://flag", "-db-url", "sqlite://crawl.db"}, nil, "sqlite://crawl.db"},
+		{"Db-url from env", []string{"-pgurl", "postgres://flag"}, map[string]string{"GEMINI_GRC_DB_URL": "sqlite://env.db"}, "sqlite://env.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := load(tt.args, tt.env)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := c.DatabaseURL(); got != tt.want {
+				t.Errorf("DatabaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	c, err := load([]string{"-dry-run"}, nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	c.NumOfWorkers = 0
+	c.ResponseTimeout = 600
+
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil")
+	}
+	for _, want := range []string{"workers", "response-timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateReportsInOrder(t *testing.T) {
+	c, err := load([]string{"-dry-run"}, nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	c.DryRunLimit = -1
+	c.MaxDepth = -1
+	c.MaxRedirects = -1
+
+	want := c.Validate().Error()
+	for range 10 {
+		if got := c.Validate().Error(); got != want {
+			t.Fatalf("Validate() error = %q, want %q", got, want)
+		}
+	}
+	redirects := strings.Index(want, "max-redirects")
+	depth := strings.Index(want, "max-depth")
+	limit := strings.Index(want, "dry-run-limit")
+	if redirects < 0 || !(redirects < depth && depth < limit) {
+		t.Errorf("Validate() error = %q, want max-redirects, max-depth and dry-run-limit in order", want)
+	}
+}
+
+func TestForHost(t *testing.T) {
+	noMaxDepth := 0
+	c := Config{
+		ResponseTimeout: 10,
+		MaxResponseSize: 1000,
+		RequestInterval: 0.5,
+		MaxDepth:        8,
+		Hosts: []HostConfig{
+			{Host: "slow.example.org", ResponseTimeout: 60, RequestInterval: 5},
+			{Host: "*.example.org", MaxResponseSize: 50, MaxDepth: &noMaxDepth},
+		},
+	}
+
+	tests := []struct {
+		host string
+		want HostSettings
+	}{
+		{"other.net", HostSettings{ResponseTimeout: 10, MaxResponseSize: 1000, RequestInterval: 500 * time.Millisecond, MaxDepth: 8}},
+		{"Slow.Example.org", HostSettings{ResponseTimeout: 60, MaxResponseSize: 1000, RequestInterval: 5 * time.Second, MaxDepth: 8}},
+		{"fast.example.org", HostSettings{ResponseTimeout: 10, MaxResponseSize: 50, RequestInterval: 500 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := c.ForHost(tt.host); got != tt.want {
+				t.Errorf("ForHost(%q) = %+v, want %+v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHostBudgets(t *testing.T) {
+	path := writeConfigFile(t, `
+pgurl: postgres://file
+host-max-urls: 1000
+max-depth: 8
+hosts:
+  - host: gemini.example.com
+    host-urls-per-cycle: 2
+    max-depth: 0
+`)
+	c, err := load([]string{"-config", path}, nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := HostSettings{ResponseTimeout: 10, MaxResponseSize: 1024 * 1024, URLsPerCycle: 2, MaxURLs: 1000, MaxDepth: 0}
+	if got := c.ForHost("gemini.example.com"); got != want {
+		t.Errorf("ForHost() = %+v, want %+v", got, want)
+	}
+	want = HostSettings{ResponseTimeout: 10, MaxResponseSize: 1024 * 1024, MaxURLs: 1000, MaxDepth: 8}
+	if got := c.ForHost("other.example.com"); got != want {
+		t.Errorf("ForHost() = %+v, want %+v", got, want)
+	}
+}
+
+func TestForResponse(t *testing.T) {
+	c := Config{
+		ResponseTimeout: 10,
+		MaxResponseSize: 1000,
+		Hosts: []HostConfig{
+			{Host: "images.example.org", MaxResponseSize: 2000},
+		},
+		Policies: []Policy{
+			{MIME: "image/*", Whitelisted: true, MaxResponseSize: 50000, ResponseTimeout: 60},
+			{Host: "*.example.org", MIME: "application/octet-stream", MaxResponseSize: 500},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		mimeType    string
+		whitelisted bool
+		want        HostSettings
+	}{
+		{"Whitelisted image", "other.net", "image/png", true, HostSettings{ResponseTimeout: 60, MaxResponseSize: 50000}},
+		{"Image", "images.example.org", "image/png", false, HostSettings{ResponseTimeout: 10, MaxResponseSize: 2000}},
+		{"Binary on matching host", "files.example.org", "application/octet-stream", false, HostSettings{ResponseTimeout: 10, MaxResponseSize: 500}},
+		{"Binary elsewhere", "other.net", "application/octet-stream", true, HostSettings{ResponseTimeout: 10, MaxResponseSize: 1000}},
+		{"No MIME type", "files.example.org", "", true, HostSettings{ResponseTimeout: 10, MaxResponseSize: 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.ForResponse(tt.host, tt.mimeType, tt.whitelisted); got != tt.want {
+				t.Errorf("ForResponse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
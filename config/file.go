@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of each flag,
+// e.g. GEMINI_GRC_BLACKLIST_PATH sets -blacklist-path.
+const EnvPrefix = "GEMINI_GRC_"
+
+// HostConfig overrides settings for the hosts
+// matching a pattern. Zero values keep the
+// global setting, except for the crawl budgets,
+// where zero means no limit and unset keeps
+// the global setting.
+type HostConfig struct {
+	Host             string  `yaml:"host"`                // Hostname, or a pattern like *.example.org
+	ResponseTimeout  int     `yaml:"response-timeout"`    // Seconds
+	MaxResponseSize  int     `yaml:"max-response-size"`   // Bytes
+	RequestInterval  float64 `yaml:"request-interval"`    // Minimum seconds between requests
+	HostURLsPerCycle *int    `yaml:"host-urls-per-cycle"` // URLs the scheduler picks per run
+	HostMaxURLs      *int    `yaml:"host-max-urls"`       // URLs queued or archived
+	MaxDepth         *int    `yaml:"max-depth"`           // Link depth from a seed or capsule root
+}
+
+// HostSettings are the settings that apply to a host.
+type HostSettings struct {
+	ResponseTimeout int // Seconds
+	MaxResponseSize int // Bytes
+	RequestInterval time.Duration
+	URLsPerCycle    int // 0 for no limit
+	MaxURLs         int // 0 for no limit
+	MaxDepth        int // 0 for no limit
+}
+
+// fileConfig is the config file. Its top-level
+// keys are flag names, e.g. "workers: 10".
+type fileConfig struct {
+	Hosts    []HostConfig   `yaml:"hosts"`
+	Policies []Policy       `yaml:"policies"`
+	Settings map[string]any `yaml:",inline"`
+}
+
+// EnvName returns the environment variable of a flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyLayers sets the flags that weren't given on the
+// command line from environment variables, or else from
+// the config file, and returns the file's sections that
+// aren't flags.
+func applyLayers(fs *flag.FlagSet, configPath string, lookupEnv func(string) (string, bool)) (*fileConfig, error) {
+	fromCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		fromCommandLine[f.Name] = true
+	})
+
+	if !fromCommandLine["config"] {
+		if value, ok := lookupEnv(EnvName("config")); ok {
+			configPath = value
+		}
+	}
+
+	file := &fileConfig{}
+	if configPath != "" {
+		var err error
+		file, err = readConfigFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range file.Settings {
+			if fs.Lookup(name) == nil || name == "config" {
+				return nil, fmt.Errorf("config file %s: unknown setting %s", configPath, name)
+			}
+			if fromCommandLine[name] {
+				continue
+			}
+			if err := fs.Set(name, settingString(value)); err != nil {
+				return nil, fmt.Errorf("config file %s: invalid %s: %w", configPath, name, err)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || fromCommandLine[f.Name] || f.Name == "config" {
+			return
+		}
+		value, ok := lookupEnv(EnvName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid %s: %w", EnvName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func readConfigFile(configPath string) (*fileConfig, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %s: %w", configPath, err)
+	}
+	return &file, nil
+}
+
+// settingString formats a config file value
+// the way it would be given as a flag. Lists
+// become comma separated.
+func settingString(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// ForHost returns the settings of a host: those of the
+// first host section matching it, the global ones otherwise.
+func (c *Config) ForHost(hostname string) HostSettings {
+	settings := HostSettings{
+		ResponseTimeout: c.ResponseTimeout,
+		MaxResponseSize: c.MaxResponseSize,
+		RequestInterval: seconds(c.RequestInterval),
+		URLsPerCycle:    c.HostURLsPerCycle,
+		MaxURLs:         c.HostMaxURLs,
+		MaxDepth:        c.MaxDepth,
+	}
+	hostname = strings.ToLower(hostname)
+	for _, h := range c.Hosts {
+		if matched, _ := path.Match(strings.ToLower(h.Host), hostname); !matched {
+			continue
+		}
+		if h.ResponseTimeout > 0 {
+			settings.ResponseTimeout = h.ResponseTimeout
+		}
+		if h.MaxResponseSize > 0 {
+			settings.MaxResponseSize = h.MaxResponseSize
+		}
+		if h.RequestInterval > 0 {
+			settings.RequestInterval = seconds(h.RequestInterval)
+		}
+		if h.HostURLsPerCycle != nil {
+			settings.URLsPerCycle = *h.HostURLsPerCycle
+		}
+		if h.HostMaxURLs != nil {
+			settings.MaxURLs = *h.HostMaxURLs
+		}
+		if h.MaxDepth != nil {
+			settings.MaxDepth = *h.MaxDepth
+		}
+		break
+	}
+	return settings
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// Policy overrides the response limits of responses
+// matching all of its conditions. Empty conditions
+// match anything, zero limits keep the host's.
+type Policy struct {
+	Host            string `yaml:"host"`              // Hostname, or a pattern like *.example.org
+	MIME            string `yaml:"mime"`              // MIME type, or a pattern like image/*
+	Whitelisted     bool   `yaml:"whitelisted"`       // Only match whitelisted URLs
+	ResponseTimeout int    `yaml:"response-timeout"`  // Seconds
+	MaxResponseSize int    `yaml:"max-response-size"` // Bytes
+}
+
+// ForResponse returns the settings of a response once its
+// MIME type is known: those of the first policy matching
+// it, the host's otherwise. The MIME type is empty for
+// responses without one, e.g. Gemini redirects.
+func (c *Config) ForResponse(hostname string, mimeType string, whitelisted bool) HostSettings {
+	settings := c.ForHost(hostname)
+	for _, p := range c.Policies {
+		if !p.matches(hostname, mimeType, whitelisted) {
+			continue
+		}
+		if p.ResponseTimeout > 0 {
+			settings.ResponseTimeout = p.ResponseTimeout
+		}
+		if p.MaxResponseSize > 0 {
+			settings.MaxResponseSize = p.MaxResponseSize
+		}
+		break
+	}
+	return settings
+}
+
+func (p Policy) matches(hostname string, mimeType string, whitelisted bool) bool {
+	if p.Whitelisted && !whitelisted {
+		return false
+	}
+	if p.Host != "" {
+		if matched, _ := path.Match(strings.ToLower(p.Host), strings.ToLower(hostname)); !matched {
+			return false
+		}
+	}
+	if p.MIME != "" {
+		if matched, _ := path.Match(strings.ToLower(p.MIME), strings.ToLower(mimeType)); !matched {
+			return false
+		}
+	}
+	return true
+}
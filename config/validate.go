@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+)
+
+// Longest response timeout we accept, in seconds.
+// Workers hold a host for this long per request.
+const maxResponseTimeout = 300
+
+// Validate checks settings that would make the crawler
+// fail later or misbehave. It reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DatabaseURL() != "" || c.DryRun, "db-url or pgurl is required, unless in dry run mode")
+	check(c.NumOfWorkers > 0, "workers must be more than 0, got %d", c.NumOfWorkers)
+	check(c.MaxDbConnections > 0, "max-db-connections must be more than 0, got %d", c.MaxDbConnections)
+	check(c.ResponseTimeout > 0 && c.ResponseTimeout <= maxResponseTimeout,
+		"response-timeout must be between 1 and %d seconds, got %d", maxResponseTimeout, c.ResponseTimeout)
+	check(c.MaxResponseSize > 0, "max-response-size must be more than 0, got %d", c.MaxResponseSize)
+	check(c.GopherSearchWindow > 0, "gopher-search-window-hours must be more than 0, got %d", c.GopherSearchWindow)
+	check(c.RequestInterval >= 0, "request-interval can't be negative, got %g", c.RequestInterval)
+	check(c.NearDuplicateSimilarity >= 0 && c.NearDuplicateSimilarity <= 1,
+		"near-duplicate-similarity must be between 0 and 1, got %g", c.NearDuplicateSimilarity)
+	// A slice rather than a map, to report in a stable order
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"max-redirects", c.MaxRedirects},
+		{"max-depth", c.MaxDepth},
+		{"host-urls-per-cycle", c.HostURLsPerCycle},
+		{"host-max-urls", c.HostMaxURLs},
+		{"skip-if-updated-days", c.SkipIfUpdatedDays},
+		{"gopher-search-max-per-host", c.GopherSearchMax},
+		{"trap-max-path-depth", c.TrapMaxPathDepth},
+		{"trap-max-repeated-segments", c.TrapMaxRepeatedSegments},
+		{"trap-max-query-variants", c.TrapMaxQueryVariants},
+		{"trap-max-host-urls-per-hour", c.TrapMaxHostURLsPerHour},
+		{"robots-ttl-hours", c.RobotsCacheTTLHours},
+		{"lists-watch-interval", c.ListsWatchInterval},
+		{"dry-run-limit", c.DryRunLimit},
+	} {
+		check(setting.value >= 0, "%s can't be negative, got %d", setting.name, setting.value)
+	}
+
+	for _, pattern := range append(slices.Clone(c.StoreMimeTypes), c.SkipMimeTypes...) {
+		_, err := path.Match(pattern, "")
+		check(err == nil, "invalid MIME type pattern %q", pattern)
+	}
+
+	for i, h := range c.Hosts {
+		_, err := path.Match(h.Host, "")
+		check(h.Host != "" && err == nil, "hosts[%d]: invalid host pattern %q", i, h.Host)
+		check(h.ResponseTimeout >= 0 && h.ResponseTimeout <= maxResponseTimeout,
+			"hosts[%d] (%s): response-timeout must be between 0 (the global setting) and %d seconds, got %d", i, h.Host, maxResponseTimeout, h.ResponseTimeout)
+		check(h.MaxResponseSize >= 0, "hosts[%d] (%s): max-response-size can't be negative, got %d", i, h.Host, h.MaxResponseSize)
+		check(h.RequestInterval >= 0, "hosts[%d] (%s): request-interval can't be negative, got %g", i, h.Host, h.RequestInterval)
+		for _, budget := range []struct {
+			name  string
+			value *int
+		}{
+			{"host-urls-per-cycle", h.HostURLsPerCycle},
+			{"host-max-urls", h.HostMaxURLs},
+			{"max-depth", h.MaxDepth},
+		} {
+			if budget.value != nil {
+				check(*budget.value >= 0, "hosts[%d] (%s): %s can't be negative, got %d", i, h.Host, budget.name, *budget.value)
+			}
+		}
+	}
+
+	for i, p := range c.Policies {
+		_, hostErr := path.Match(p.Host, "")
+		check(hostErr == nil, "policies[%d]: invalid host pattern %q", i, p.Host)
+		_, mimeErr := path.Match(p.MIME, "")
+		check(mimeErr == nil, "policies[%d]: invalid MIME type pattern %q", i, p.MIME)
+		check(p.ResponseTimeout >= 0 && p.ResponseTimeout <= maxResponseTimeout,
+			"policies[%d]: response-timeout must be between 0 (the host's setting) and %d seconds, got %d", i, maxResponseTimeout, p.ResponseTimeout)
+		check(p.MaxResponseSize >= 0, "policies[%d]: max-response-size can't be negative, got %d", i, p.MaxResponseSize)
+		check(p.ResponseTimeout > 0 || p.MaxResponseSize > 0, "policies[%d]: sets neither response-timeout nor max-response-size", i)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
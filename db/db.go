@@ -16,6 +16,7 @@ import (
 	commonUrl "gemini-grc/common/url"
 	"gemini-grc/config"
 	"gemini-grc/contextutil"
+	"gemini-grc/protocol"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
 	"github.com/guregu/null/v5"
@@ -32,11 +33,18 @@ type DbService interface {
 
 	// URL methods
 	InsertURL(ctx context.Context, tx *sqlx.Tx, url string) error
+	InsertURLWithDepth(ctx context.Context, tx *sqlx.Tx, url string, depth int) error
+	InsertPriorityURL(ctx context.Context, tx *sqlx.Tx, url string, priority int) error
+	DeleteHostURLs(ctx context.Context, tx *sqlx.Tx, host string) (int64, error)
+	GetURLDepth(ctx context.Context, tx *sqlx.Tx, url string) (int, error)
+	CountHostURLs(ctx context.Context, tx *sqlx.Tx, host string) (int, error)
 	CheckAndUpdateNormalizedURL(ctx context.Context, tx *sqlx.Tx, url string, normalizedURL string) error
 	DeleteURL(ctx context.Context, tx *sqlx.Tx, url string) error
+	IsURLQueued(ctx context.Context, tx *sqlx.Tx, url string) (bool, error)
 	MarkURLsAsBeingProcessed(ctx context.Context, tx *sqlx.Tx, urls []string) error
 	GetUrlHosts(ctx context.Context, tx *sqlx.Tx) ([]string, error)
-	GetRandomUrlsFromHosts(ctx context.Context, hosts []string, limit int, tx *sqlx.Tx) ([]string, error)
+	CountURLs(ctx context.Context, tx *sqlx.Tx) (int, error)
+	GetRandomUrlsFromHosts(ctx context.Context, hostLimits map[string]int, tx *sqlx.Tx) ([]string, error)
 
 	// Snapshot methods
 	SaveSnapshot(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error
@@ -47,8 +55,154 @@ type DbService interface {
 	GetAllSnapshotsForURL(ctx context.Context, tx *sqlx.Tx, url string) ([]*snapshot.Snapshot, error)
 	GetSnapshotsByDateRange(ctx context.Context, tx *sqlx.Tx, url string, startTime, endTime time.Time) ([]*snapshot.Snapshot, error)
 	IsContentIdentical(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) (bool, error)
+	GetLatestSimHash(ctx context.Context, tx *sqlx.Tx, url string) (null.Int, error)
+	HasSnapshotSince(ctx context.Context, tx *sqlx.Tx, url string, since time.Time) (bool, error)
+	GetURLsToRecrawl(ctx context.Context, tx *sqlx.Tx, cutoff time.Time, limit int) ([]string, error)
+
+	// robots.txt methods
+	GetRobotsEntry(ctx context.Context, tx *sqlx.Tx, hostKey string) (*RobotsEntry, error)
+	SaveRobotsEntry(ctx context.Context, tx *sqlx.Tx, e *RobotsEntry) error
+	GetExpiredRobotsKeys(ctx context.Context, tx *sqlx.Tx, limit int) ([]string, error)
+
+	// Gopher search methods
+	InsertGopherSearch(ctx context.Context, tx *sqlx.Tx, search *GopherSearch) error
+	CountGopherSearches(ctx context.Context, tx *sqlx.Tx, host string, since time.Time) (int, error)
+
+	// Gemini input endpoint methods
+	SaveInputEndpoint(ctx context.Context, tx *sqlx.Tx, e *InputEndpoint) error
+
+	// Redirect methods
+	SaveRedirect(ctx context.Context, tx *sqlx.Tx, r *Redirect) error
+	GetCanonicalURL(ctx context.Context, tx *sqlx.Tx, url string) (string, error)
+	SaveCanonicalURL(ctx context.Context, tx *sqlx.Tx, url string, canonicalURL string) error
+
+	// Spider trap methods
+	QuarantineURL(ctx context.Context, tx *sqlx.Tx, q *QuarantinedURL) error
+
+	// Removal methods
+	GetQueuedURLs(ctx context.Context, tx *sqlx.Tx, f URLFilter) ([]string, error)
+	GetSnapshotURLs(ctx context.Context, tx *sqlx.Tx, f URLFilter) ([]string, error)
+	DeleteURLs(ctx context.Context, tx *sqlx.Tx, urls []string) error
+	MarkSnapshotsRemoved(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error)
+	DeleteSnapshots(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error)
+	LogRemovals(ctx context.Context, tx *sqlx.Tx, rs []Removal) error
+
+	// Takedown methods
+	AddTakedownRule(ctx context.Context, tx *sqlx.Tx, r *TakedownRule) (int, error)
+	GetTakedownRules(ctx context.Context, tx *sqlx.Tx, includeRevoked bool) ([]TakedownRule, error)
+	RevokeTakedownRule(ctx context.Context, tx *sqlx.Tx, id int) (bool, error)
+	TombstoneSnapshots(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error)
 }
 
+// TakedownRule matches URLs whose authors asked
+// us not to crawl or archive them.
+type TakedownRule struct {
+	ID        int       `db:"id"`
+	Kind      string    `db:"kind"` // One of the TakedownKind constants
+	Pattern   string    `db:"pattern"`
+	Reason    string    `db:"reason"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+	RevokedAt null.Time `db:"revoked_at"`
+}
+
+// Takedown rule kinds
+const (
+	TakedownKindHost   = "host"   // A hostname, e.g. example.org
+	TakedownKindPrefix = "prefix" // A normalized URL prefix
+	TakedownKindRegex  = "regex"
+)
+
+// URLFilter narrows down the URLs GetQueuedURLs
+// and GetSnapshotURLs return. The zero value
+// matches every URL.
+type URLFilter struct {
+	Prefix string // URLs that start with it
+	Host   string // URLs of the host
+}
+
+// likePattern returns the LIKE pattern of the filter.
+func (f URLFilter) likePattern() string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(f.Prefix) + "%"
+}
+
+// Removal is an audit log entry of a URL
+// removed from the queue or the archive.
+type Removal struct {
+	URL       string    `db:"url"`
+	Target    string    `db:"target"` // One of the RemovalTarget constants
+	Action    string    `db:"action"` // One of the RemovalAction constants
+	Snapshots int64     `db:"snapshots"`
+	RemovedBy string    `db:"removed_by"`
+	Reason    string    `db:"reason"`
+	RemovedAt time.Time `db:"removed_at"`
+}
+
+// What was removed, and how
+const (
+	RemovalTargetQueue     = "queue"
+	RemovalTargetSnapshots = "snapshots"
+	RemovalActionDelete    = "delete"
+	RemovalActionMark      = "mark"
+	RemovalActionTombstone = "tombstone"
+)
+
+// QuarantinedURL is a link that looks like a
+// spider trap, kept out of the queue for review.
+type QuarantinedURL struct {
+	URL       string    `db:"url"`
+	Host      string    `db:"host"`
+	SourceURL string    `db:"source_url"` // The page that linked to it
+	Reason    string    `db:"reason"`
+	Seen      time.Time `db:"seen"`
+}
+
+// Redirect is a redirect response, an edge
+// from the URL we asked for to its target.
+type Redirect struct {
+	FromURL string    `db:"from_url"`
+	ToURL   string    `db:"to_url"`
+	Code    int       `db:"code"`
+	SeenAt  time.Time `db:"seen_at"`
+}
+
+// InputEndpoint is a Gemini URL that asks for input.
+type InputEndpoint struct {
+	URL       string    `db:"url"`
+	Host      string    `db:"host"`
+	Prompt    string    `db:"prompt"`
+	Sensitive bool      `db:"sensitive"` // Status 11, e.g. passwords
+	Seen      time.Time `db:"seen"`
+}
+
+// GopherSearch is a query we ran against a Gopher
+// search server, and the menu that linked to it.
+type GopherSearch struct {
+	URL       string    `db:"url"` // The search URL, query included
+	Host      string    `db:"host"`
+	MenuURL   string    `db:"menu_url"` // The menu with the search item
+	Query     string    `db:"query"`
+	Timestamp time.Time `db:"timestamp"`
+}
+
+// RobotsEntry is the cached robots.txt of a host.
+type RobotsEntry struct {
+	HostKey   string             `db:"host_key"` // scheme://host:port
+	Body      null.String        `db:"body"`     // Raw robots.txt content
+	Rules     null.Value[[]byte] `db:"rules"`    // Parsed rules as JSON
+	Status    string             `db:"status"`   // One of the RobotsStatus constants
+	FetchedAt time.Time          `db:"fetched_at"`
+	ExpiresAt time.Time          `db:"expires_at"`
+}
+
+// robots.txt fetch statuses
+const (
+	RobotsStatusOK      = "ok"
+	RobotsStatusMissing = "missing"
+	RobotsStatusError   = "error"
+)
+
 type DbServiceImpl struct {
 	db        *sqlx.DB
 	connected bool
@@ -56,7 +210,9 @@ type DbServiceImpl struct {
 	mu sync.Mutex
 }
 
-var Database DbServiceImpl
+// Database is the PostgreSQL or SQLite database,
+// or the memory one in dry run mode.
+var Database DbService = &DbServiceImpl{} //nolint:gochecknoglobals
 
 // IsDeadlockError checks if the error is a PostgreSQL deadlock error.
 func IsDeadlockError(err error) bool {
@@ -87,7 +243,7 @@ func (d *DbServiceImpl) Initialize(ctx context.Context) error {
 	}
 
 	// Create a connection pool
-	connStr := config.CONFIG.PgURL
+	connStr := config.CONFIG.DatabaseURL()
 	db, err := sqlx.Open("pgx", connStr)
 	if err != nil {
 		contextlog.LogErrorWithContext(dbCtx, logging.GetSlogger(), "Unable to connect to database with URL %s: %v", connStr, err)
@@ -121,7 +277,7 @@ func (d *DbServiceImpl) Initialize(ctx context.Context) error {
 func (d *DbServiceImpl) Shutdown(ctx context.Context) error {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
 	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Shutting down database connections")
-	_, err := d.db.Query("UPDATE urls SET being_processed=false")
+	_, err := d.db.ExecContext(ctx, "UPDATE urls SET being_processed=false")
 	if err != nil {
 		contextlog.LogErrorWithContext(dbCtx, logging.GetSlogger(), "Unable to update urls table: %v", err)
 	}
@@ -168,8 +324,14 @@ func (d *DbServiceImpl) NewTx(ctx context.Context) (*sqlx.Tx, error) {
 
 // InsertURL inserts a URL with context
 func (d *DbServiceImpl) InsertURL(ctx context.Context, tx *sqlx.Tx, url string) error {
+	return d.InsertURLWithDepth(ctx, tx, url, 0)
+}
+
+// InsertURLWithDepth inserts a URL found
+// the given number of links from a root.
+func (d *DbServiceImpl) InsertURLWithDepth(ctx context.Context, tx *sqlx.Tx, url string, depth int) error {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
-	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Inserting URL %s", url)
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Inserting URL %s (depth %d)", url, depth)
 
 	// Check if the context is cancelled before proceeding
 	if err := ctx.Err(); err != nil {
@@ -186,10 +348,12 @@ func (d *DbServiceImpl) InsertURL(ctx context.Context, tx *sqlx.Tx, url string)
 		Url       string
 		Host      string
 		Timestamp time.Time
+		Depth     int
 	}{
 		Url:       normalizedURL.Full,
 		Host:      normalizedURL.Hostname,
 		Timestamp: time.Now(),
+		Depth:     depth,
 	}
 
 	query := SQL_INSERT_URL
@@ -200,6 +364,91 @@ func (d *DbServiceImpl) InsertURL(ctx context.Context, tx *sqlx.Tx, url string)
 	return nil
 }
 
+// InsertPriorityURL inserts a URL that's crawled
+// before URLs of its host with a lower priority.
+func (d *DbServiceImpl) InsertPriorityURL(ctx context.Context, tx *sqlx.Tx, url string, priority int) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Inserting URL %s with priority %d", url, priority)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	normalizedURL, err := commonUrl.ParseURL(url, "", true)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, SQL_INSERT_PRIORITY_URL, normalizedURL.Full, normalizedURL.Hostname, time.Now(), priority)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot insert URL: database error %w URL %s", err, url), 0, "", true)
+	}
+	return nil
+}
+
+// DeleteHostURLs removes the queued URLs of
+// a host, and returns how many there were.
+func (d *DbServiceImpl) DeleteHostURLs(ctx context.Context, tx *sqlx.Tx, host string) (int64, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Deleting queued URLs of host %s", host)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would delete queued URLs of host %s", host)
+		return 0, nil
+	}
+
+	result, err := tx.ExecContext(ctx, SQL_DELETE_HOST_URLS, host)
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot delete URLs of host %s: %w", host, err), 0, "", true)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot delete URLs of host %s: %w", host, err), 0, "", true)
+	}
+	return deleted, nil
+}
+
+// GetURLDepth returns the link depth of
+// a queued URL, or 0 if it isn't queued.
+func (d *DbServiceImpl) GetURLDepth(ctx context.Context, tx *sqlx.Tx, url string) (int, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var depth int
+	err := tx.GetContext(ctx, &depth, SQL_GET_URL_DEPTH, url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, xerrors.NewError(fmt.Errorf("cannot get depth of URL %s: %w", url, err), 0, "", true)
+	}
+	return depth, nil
+}
+
+// CountHostURLs counts the URLs of
+// a host we queued or archived.
+func (d *DbServiceImpl) CountHostURLs(ctx context.Context, tx *sqlx.Tx, host string) (int, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.GetContext(ctx, &count, SQL_COUNT_HOST_URLS, host)
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot count URLs of host %s: %w", host, err), 0, "", true)
+	}
+	return count, nil
+}
+
 // NormalizeURL normalizes a URL with context
 func (d *DbServiceImpl) CheckAndUpdateNormalizedURL(ctx context.Context, tx *sqlx.Tx, url string, normalizedURL string) error {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
@@ -250,6 +499,21 @@ func (d *DbServiceImpl) DeleteURL(ctx context.Context, tx *sqlx.Tx, url string)
 	return nil
 }
 
+// IsURLQueued checks if a URL is in the queue.
+func (d *DbServiceImpl) IsURLQueued(ctx context.Context, tx *sqlx.Tx, url string) (bool, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var result []bool
+	err := tx.SelectContext(ctx, &result, SQL_IS_URL_QUEUED, url)
+	if err != nil {
+		return false, xerrors.NewError(fmt.Errorf("cannot check if URL %s is queued: %w", url, err), 0, "", true)
+	}
+	return len(result) > 0, nil
+}
+
 // MarkURLsAsBeingProcessed marks URLs as being processed with context
 func (d *DbServiceImpl) MarkURLsAsBeingProcessed(ctx context.Context, tx *sqlx.Tx, urls []string) error {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
@@ -284,6 +548,32 @@ func (d *DbServiceImpl) MarkURLsAsBeingProcessed(ctx context.Context, tx *sqlx.T
 	return nil
 }
 
+// URLSchemeFilter returns an SQL condition that
+// matches URLs of the enabled protocols only.
+func URLSchemeFilter() string {
+	schemes := protocol.EnabledSchemes()
+	conditions := make([]string, len(schemes))
+	for i, scheme := range schemes {
+		conditions[i] = fmt.Sprintf("url LIKE '%s://%%'", scheme)
+	}
+	return fmt.Sprintf("(%s)", strings.Join(conditions, " OR "))
+}
+
+// CountURLs counts the queued URLs of enabled protocols.
+func (d *DbServiceImpl) CountURLs(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.GetContext(ctx, &count, fmt.Sprintf("SELECT COUNT(*) FROM urls WHERE %s", URLSchemeFilter()))
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot count URLs: %w", err), 0, "", true)
+	}
+	return count, nil
+}
+
 // GetUrlHosts gets URL hosts with context
 func (d *DbServiceImpl) GetUrlHosts(ctx context.Context, tx *sqlx.Tx) ([]string, error) {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
@@ -296,12 +586,7 @@ func (d *DbServiceImpl) GetUrlHosts(ctx context.Context, tx *sqlx.Tx) ([]string,
 
 	// Context-aware implementation
 	var hosts []string
-	var query string
-	if config.CONFIG.GopherEnable {
-		query = "SELECT DISTINCT(host) FROM urls WHERE being_processed IS NOT TRUE"
-	} else {
-		query = "SELECT DISTINCT(host) FROM urls WHERE url like 'gemini://%' AND being_processed IS NOT TRUE"
-	}
+	query := fmt.Sprintf("SELECT DISTINCT(host) FROM urls WHERE %s AND being_processed IS NOT TRUE", URLSchemeFilter())
 	err := tx.SelectContext(ctx, &hosts, query)
 	if err != nil {
 		return nil, xerrors.NewError(err, 0, "", true)
@@ -309,10 +594,11 @@ func (d *DbServiceImpl) GetUrlHosts(ctx context.Context, tx *sqlx.Tx) ([]string,
 	return hosts, nil
 }
 
-// GetRandomUrlsFromHosts gets random URLs from hosts with context
-func (d *DbServiceImpl) GetRandomUrlsFromHosts(ctx context.Context, hosts []string, limit int, tx *sqlx.Tx) ([]string, error) {
+// GetRandomUrlsFromHosts gets random URLs from hosts with context,
+// at most hostLimits[host] from each, and marks them as being processed.
+func (d *DbServiceImpl) GetRandomUrlsFromHosts(ctx context.Context, hostLimits map[string]int, tx *sqlx.Tx) ([]string, error) {
 	dbCtx := contextutil.ContextWithComponent(ctx, "database")
-	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Getting random URLs from %d hosts with limit %d", len(hosts), limit)
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Getting random URLs from %d hosts", len(hostLimits))
 
 	// Check if the context is cancelled before proceeding
 	if err := ctx.Err(); err != nil {
@@ -321,14 +607,9 @@ func (d *DbServiceImpl) GetRandomUrlsFromHosts(ctx context.Context, hosts []stri
 
 	// Context-aware implementation
 	var urls []string
-	var query string
-	for _, host := range hosts {
+	query := fmt.Sprintf("SELECT url FROM urls WHERE host=$1 AND %s AND being_processed IS NOT TRUE ORDER BY priority DESC, RANDOM() LIMIT $2", URLSchemeFilter())
+	for host, limit := range hostLimits {
 		var results []string
-		if !config.CONFIG.GopherEnable {
-			query = "SELECT url FROM urls WHERE host=$1 AND url like 'gemini://%' AND being_processed IS NOT TRUE ORDER BY RANDOM() LIMIT $2"
-		} else {
-			query = "SELECT url FROM urls WHERE host=$1 AND being_processed IS NOT TRUE ORDER BY RANDOM() LIMIT $2"
-		}
 		err := tx.SelectContext(ctx, &results, query, host, limit)
 		if err != nil {
 			return nil, xerrors.NewError(err, 0, "", true)
@@ -540,14 +821,592 @@ func (d *DbServiceImpl) IsContentIdentical(ctx context.Context, tx *sqlx.Tx, s *
 		return false, err
 	}
 
-	// Check if the content is identical
-	if s.GemText.Valid && latestSnapshot.GemText.Valid {
-		return s.GemText.String == latestSnapshot.GemText.String, nil
-	} else if s.Data.Valid && latestSnapshot.Data.Valid {
-		return bytes.Equal(s.Data.V, latestSnapshot.Data.V), nil
+	return contentIdentical(s, latestSnapshot), nil
+}
+
+// contentIdentical checks if a snapshot has the same content
+// as the previous one. Without stored content, it compares
+// the headers instead.
+func contentIdentical(s *snapshot.Snapshot, previous *snapshot.Snapshot) bool {
+	if s.SkippedContent && previous.SkippedContent {
+		return s.Header.Valid && s.Header.String == previous.Header.String
+	} else if s.GemText.Valid && previous.GemText.Valid {
+		return s.GemText.String == previous.GemText.String
+	} else if s.Data.Valid && previous.Data.Valid {
+		return bytes.Equal(s.Data.V, previous.Data.V)
+	}
+	return false
+}
+
+// GetLatestSimHash gets the fingerprint of the latest
+// snapshot of a URL. It's null if there's no snapshot,
+// or the latest one has no text content.
+func (d *DbServiceImpl) GetLatestSimHash(ctx context.Context, tx *sqlx.Tx, url string) (null.Int, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return null.Int{}, err
 	}
 
-	return false, nil
+	var simHash null.Int
+	err := tx.GetContext(ctx, &simHash, SQL_GET_LATEST_SIMHASH, url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return null.Int{}, nil
+		}
+		return null.Int{}, xerrors.NewError(fmt.Errorf("cannot get SimHash of %s: %w", url, err), 0, "", true)
+	}
+	return simHash, nil
+}
+
+// HasSnapshotSince checks if a URL
+// was archived after the given time.
+func (d *DbServiceImpl) HasSnapshotSince(ctx context.Context, tx *sqlx.Tx, url string, since time.Time) (bool, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var result []bool
+	err := tx.SelectContext(ctx, &result, SQL_HAS_SNAPSHOT_SINCE, url, since)
+	if err != nil {
+		return false, xerrors.NewError(fmt.Errorf("cannot check recent snapshots of %s: %w", url, err), 0, "", true)
+	}
+	return len(result) > 0, nil
+}
+
+// GetURLsToRecrawl picks up to limit capsule roots,
+// one per host, that weren't crawled since cutoff.
+func (d *DbServiceImpl) GetURLsToRecrawl(ctx context.Context, tx *sqlx.Tx, cutoff time.Time, limit int) ([]string, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		URL  string `db:"url"`
+		Host string `db:"host"`
+	}
+	err := tx.SelectContext(ctx, &rows, SQL_FETCH_SNAPSHOTS_FROM_HISTORY, cutoff, limit)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot get URLs to recrawl: %w", err), 0, "", true)
+	}
+	urls := make([]string, len(rows))
+	for i, row := range rows {
+		urls[i] = row.URL
+	}
+	return urls, nil
+}
+
+// GetRobotsEntry gets the cached robots.txt of a host,
+// or nil if we haven't fetched it yet.
+func (d *DbServiceImpl) GetRobotsEntry(ctx context.Context, tx *sqlx.Tx, hostKey string) (*RobotsEntry, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Getting robots.txt entry for %s", hostKey)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e := &RobotsEntry{}
+	err := tx.GetContext(ctx, e, SQL_GET_ROBOTS, hostKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, xerrors.NewError(fmt.Errorf("cannot get robots.txt entry for %s: %w", hostKey, err), 0, "", true)
+	}
+	return e, nil
+}
+
+// SaveRobotsEntry stores the robots.txt of a host,
+// and records it in the history if the rules changed.
+func (d *DbServiceImpl) SaveRobotsEntry(ctx context.Context, tx *sqlx.Tx, e *RobotsEntry) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Saving robots.txt entry for %s", e.HostKey)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would save robots.txt entry for %s", e.HostKey)
+		return nil
+	}
+
+	previous, err := d.GetRobotsEntry(ctx, tx, e.HostKey)
+	if err != nil {
+		return err
+	}
+
+	if previous == nil || !bytes.Equal(previous.Rules.ValueOrZero(), e.Rules.ValueOrZero()) {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "robots.txt rules changed for %s", e.HostKey)
+		_, err = tx.NamedExecContext(ctx, SQL_INSERT_ROBOTS_HISTORY, e)
+		if err != nil {
+			return xerrors.NewError(fmt.Errorf("cannot save robots.txt history for %s: %w", e.HostKey, err), 0, "", true)
+		}
+	}
+
+	_, err = tx.NamedExecContext(ctx, SQL_UPSERT_ROBOTS, e)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot save robots.txt entry for %s: %w", e.HostKey, err), 0, "", true)
+	}
+	return nil
+}
+
+// GetExpiredRobotsKeys gets the host keys of
+// robots.txt entries that should be refreshed.
+func (d *DbServiceImpl) GetExpiredRobotsKeys(ctx context.Context, tx *sqlx.Tx, limit int) ([]string, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Getting up to %d expired robots.txt entries", limit)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err := tx.SelectContext(ctx, &keys, SQL_GET_EXPIRED_ROBOTS, time.Now(), limit)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot get expired robots.txt entries: %w", err), 0, "", true)
+	}
+	return keys, nil
+}
+
+// InsertGopherSearch records a Gopher search.
+func (d *DbServiceImpl) InsertGopherSearch(ctx context.Context, tx *sqlx.Tx, search *GopherSearch) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Inserting Gopher search %s", search.URL)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would insert Gopher search %s", search.URL)
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, SQL_INSERT_GOPHER_SEARCH, search)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot insert Gopher search %s: %w", search.URL, err), 0, "", true)
+	}
+	return nil
+}
+
+// CountGopherSearches counts the searches
+// we ran against a host after since.
+func (d *DbServiceImpl) CountGopherSearches(ctx context.Context, tx *sqlx.Tx, host string, since time.Time) (int, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.GetContext(ctx, &count, SQL_COUNT_GOPHER_SEARCHES, host, since)
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot count Gopher searches for %s: %w", host, err), 0, "", true)
+	}
+	return count, nil
+}
+
+// SaveInputEndpoint records an input endpoint, or
+// updates its prompt if we have seen it before.
+func (d *DbServiceImpl) SaveInputEndpoint(ctx context.Context, tx *sqlx.Tx, e *InputEndpoint) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Saving input endpoint %s", e.URL)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would save input endpoint %s", e.URL)
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, SQL_UPSERT_INPUT_ENDPOINT, e)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot save input endpoint %s: %w", e.URL, err), 0, "", true)
+	}
+	return nil
+}
+
+// SaveRedirect records a redirect, or updates
+// when we last saw it if we have seen it before.
+func (d *DbServiceImpl) SaveRedirect(ctx context.Context, tx *sqlx.Tx, r *Redirect) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Saving redirect %s -> %s", r.FromURL, r.ToURL)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would save redirect %s -> %s", r.FromURL, r.ToURL)
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, SQL_UPSERT_REDIRECT, r)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot save redirect %s -> %s: %w", r.FromURL, r.ToURL, err), 0, "", true)
+	}
+	return nil
+}
+
+// GetCanonicalURL returns where a URL moved
+// permanently, or the URL itself if it didn't.
+func (d *DbServiceImpl) GetCanonicalURL(ctx context.Context, tx *sqlx.Tx, url string) (string, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var canonicalURL string
+	err := tx.GetContext(ctx, &canonicalURL, SQL_GET_CANONICAL_URL, url)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return url, nil
+		}
+		return "", xerrors.NewError(fmt.Errorf("cannot get canonical URL of %s: %w", url, err), 0, "", true)
+	}
+	return canonicalURL, nil
+}
+
+// SaveCanonicalURL records that a URL moved permanently.
+// Mappings are kept one level deep: URLs that moved to
+// url now point to its new canonical URL as well.
+func (d *DbServiceImpl) SaveCanonicalURL(ctx context.Context, tx *sqlx.Tx, url string, canonicalURL string) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Saving canonical URL %s -> %s", url, canonicalURL)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would save canonical URL %s -> %s", url, canonicalURL)
+		return nil
+	}
+
+	// The target may have moved itself.
+	resolved, err := d.GetCanonicalURL(ctx, tx, canonicalURL)
+	if err != nil {
+		return err
+	}
+	// If it moved back to url, the mapping is stale
+	// since url is the one that redirects now.
+	if resolved == url {
+		_, err = tx.ExecContext(ctx, SQL_DELETE_CANONICAL_URL, canonicalURL)
+		if err != nil {
+			return xerrors.NewError(fmt.Errorf("cannot delete canonical URL of %s: %w", canonicalURL, err), 0, "", true)
+		}
+		resolved = canonicalURL
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, SQL_UPSERT_CANONICAL_URL, url, resolved, now)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot save canonical URL of %s: %w", url, err), 0, "", true)
+	}
+	_, err = tx.ExecContext(ctx, SQL_UPDATE_CANONICAL_URLS, url, resolved, now)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot update canonical URLs pointing to %s: %w", url, err), 0, "", true)
+	}
+	return nil
+}
+
+// QuarantineURL records a suspected spider trap URL,
+// or counts another hit if it's quarantined already.
+func (d *DbServiceImpl) QuarantineURL(ctx context.Context, tx *sqlx.Tx, q *QuarantinedURL) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Quarantining URL %s: %s", q.URL, q.Reason)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would quarantine URL %s", q.URL)
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, SQL_UPSERT_QUARANTINED_URL, q)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot quarantine URL %s: %w", q.URL, err), 0, "", true)
+	}
+	return nil
+}
+
+// GetQueuedURLs returns the queued URLs the
+// filter matches, of enabled protocols or not.
+func (d *DbServiceImpl) GetQueuedURLs(ctx context.Context, tx *sqlx.Tx, f URLFilter) ([]string, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	err := tx.SelectContext(ctx, &urls, SQL_GET_QUEUED_URLS, f.likePattern(), f.Host)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot get queued URLs: %w", err), 0, "", true)
+	}
+	return urls, nil
+}
+
+// GetSnapshotURLs returns the URLs the
+// filter matches that have snapshots.
+func (d *DbServiceImpl) GetSnapshotURLs(ctx context.Context, tx *sqlx.Tx, f URLFilter) ([]string, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	err := tx.SelectContext(ctx, &urls, SQL_GET_SNAPSHOT_URLS, f.likePattern(), f.Host)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot get snapshot URLs: %w", err), 0, "", true)
+	}
+	return urls, nil
+}
+
+// DeleteURLs deletes a batch of queued URLs
+// in one statement.
+func (d *DbServiceImpl) DeleteURLs(ctx context.Context, tx *sqlx.Tx, urls []string) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Deleting %d URLs", len(urls))
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(urls) == 0 {
+		return nil
+	}
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would delete %d URLs", len(urls))
+		return nil
+	}
+
+	query, args, err := sqlx.In(SQL_DELETE_URLS, urls)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot delete %d URLs: %w", len(urls), err), 0, "", true)
+	}
+	_, err = tx.ExecContext(ctx, tx.Rebind(query), args...)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot delete %d URLs: %w", len(urls), err), 0, "", true)
+	}
+	return nil
+}
+
+// MarkSnapshotsRemoved hides the snapshots of a batch
+// of URLs from the archive, keeping their content, and
+// returns how many of each URL weren't hidden already.
+func (d *DbServiceImpl) MarkSnapshotsRemoved(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Marking snapshots of %d URLs as removed", len(urls))
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(urls) == 0 {
+		return map[string]int64{}, nil
+	}
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would mark snapshots of %d URLs as removed", len(urls))
+		return map[string]int64{}, nil
+	}
+
+	counts, err := countReturnedURLs(ctx, tx, SQL_MARK_SNAPSHOTS_REMOVED, time.Now(), urls)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot mark snapshots of %d URLs as removed: %w", len(urls), err), 0, "", true)
+	}
+	return counts, nil
+}
+
+// DeleteSnapshots deletes every snapshot of a batch of
+// URLs, and returns how many each URL had.
+func (d *DbServiceImpl) DeleteSnapshots(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Deleting snapshots of %d URLs", len(urls))
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(urls) == 0 {
+		return map[string]int64{}, nil
+	}
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would delete snapshots of %d URLs", len(urls))
+		return map[string]int64{}, nil
+	}
+
+	counts, err := countReturnedURLs(ctx, tx, SQL_DELETE_SNAPSHOTS, urls)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot delete snapshots of %d URLs: %w", len(urls), err), 0, "", true)
+	}
+	return counts, nil
+}
+
+// countReturnedURLs runs a statement that returns
+// the url of every row it changes, and counts the
+// rows per URL. Slice arguments are expanded for IN.
+func countReturnedURLs(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (map[string]int64, error) {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	err = tx.SelectContext(ctx, &urls, tx.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, url := range urls {
+		counts[url]++
+	}
+	return counts, nil
+}
+
+// LogRemovals adds a batch of entries to
+// the removal audit log in one statement.
+func (d *DbServiceImpl) LogRemovals(ctx context.Context, tx *sqlx.Tx, rs []Removal) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(rs) == 0 {
+		return nil
+	}
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would log %d removals", len(rs))
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, SQL_INSERT_REMOVAL, rs)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot log %d removals: %w", len(rs), err), 0, "", true)
+	}
+	return nil
+}
+
+// AddTakedownRule adds a rule and returns its ID.
+func (d *DbServiceImpl) AddTakedownRule(ctx context.Context, tx *sqlx.Tx, r *TakedownRule) (int, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Adding takedown rule %s %s", r.Kind, r.Pattern)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would add takedown rule %s %s", r.Kind, r.Pattern)
+		return 0, nil
+	}
+
+	rows, err := sqlx.NamedQueryContext(ctx, tx, SQL_INSERT_TAKEDOWN_RULE, r)
+	if err != nil {
+		return 0, xerrors.NewError(fmt.Errorf("cannot add takedown rule: %w", err), 0, "", true)
+	}
+	defer rows.Close()
+
+	var id int
+	if rows.Next() {
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, xerrors.NewError(fmt.Errorf("cannot add takedown rule: %w", err), 0, "", true)
+		}
+	}
+	return id, nil
+}
+
+// GetTakedownRules returns the active rules,
+// and optionally the revoked ones.
+func (d *DbServiceImpl) GetTakedownRules(ctx context.Context, tx *sqlx.Tx, includeRevoked bool) ([]TakedownRule, error) {
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query := SQL_GET_ACTIVE_TAKEDOWN_RULES
+	if includeRevoked {
+		query = SQL_GET_ALL_TAKEDOWN_RULES
+	}
+	var rules []TakedownRule
+	err := tx.SelectContext(ctx, &rules, query)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot get takedown rules: %w", err), 0, "", true)
+	}
+	return rules, nil
+}
+
+// RevokeTakedownRule revokes a rule, and reports
+// if there was an active rule with that ID.
+func (d *DbServiceImpl) RevokeTakedownRule(ctx context.Context, tx *sqlx.Tx, id int) (bool, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Revoking takedown rule %d", id)
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would revoke takedown rule %d", id)
+		return false, nil
+	}
+
+	result, err := tx.ExecContext(ctx, SQL_REVOKE_TAKEDOWN_RULE, id, time.Now())
+	if err != nil {
+		return false, xerrors.NewError(fmt.Errorf("cannot revoke takedown rule %d: %w", id, err), 0, "", true)
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return false, xerrors.NewError(fmt.Errorf("cannot revoke takedown rule %d: %w", id, err), 0, "", true)
+	}
+	return revoked > 0, nil
+}
+
+// TombstoneSnapshots clears the content of the snapshots
+// of a batch of URLs and hides them from the archive. It
+// returns how many of each URL weren't tombstoned already.
+func (d *DbServiceImpl) TombstoneSnapshots(ctx context.Context, tx *sqlx.Tx, urls []string) (map[string]int64, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Tombstoning snapshots of %d URLs", len(urls))
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(urls) == 0 {
+		return map[string]int64{}, nil
+	}
+	if config.CONFIG.DryRun {
+		contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Would tombstone snapshots of %d URLs", len(urls))
+		return map[string]int64{}, nil
+	}
+
+	counts, err := countReturnedURLs(ctx, tx, SQL_TOMBSTONE_SNAPSHOTS, time.Now(), urls)
+	if err != nil {
+		return nil, xerrors.NewError(fmt.Errorf("cannot tombstone snapshots of %d URLs: %w", len(urls), err), 0, "", true)
+	}
+	return counts, nil
 }
 
 // SafeRollback attempts to roll back a transaction,
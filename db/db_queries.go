@@ -64,17 +64,54 @@ ORDER BY
     RANDOM()
 FOR UPDATE SKIP LOCKED
 LIMIT $1
+`
+	// Picks and marks the URLs of a host to crawl, for SQLite.
+	// Parameters: $1 = host, $2 = limit
+	SQL_TAKE_RANDOM_HOST_URLS = `
+UPDATE urls SET being_processed = true
+WHERE url IN (
+    SELECT url FROM urls
+    WHERE host = $1 AND %s AND being_processed IS NOT TRUE
+    ORDER BY priority DESC, RANDOM()
+    LIMIT $2
+)
+RETURNING url
 `
 	// New query - always insert a new snapshot without conflict handling
 	SQL_INSERT_SNAPSHOT = `
-        INSERT INTO snapshots (url, host, timestamp, mimetype, data, gemtext, links, lang, response_code, error, header, last_crawled)
-        VALUES (:url, :host, :timestamp, :mimetype, :data, :gemtext, :links, :lang, :response_code, :error, :header, :last_crawled)
+        INSERT INTO snapshots (url, host, timestamp, mimetype, data, gemtext, links, lang, response_code, error, header, last_crawled, simhash, skipped_content)
+        VALUES (:url, :host, :timestamp, :mimetype, :data, :gemtext, :links, :lang, :response_code, :error, :header, :last_crawled, :simhash, :skipped_content)
         RETURNING id
     `
+	// A URL found again closer to a root keeps the lower depth.
 	SQL_INSERT_URL = `
-        INSERT INTO urls (url, host, timestamp)
-        VALUES (:url, :host, :timestamp)
-        ON CONFLICT (url) DO NOTHING
+        INSERT INTO urls (url, host, timestamp, depth)
+        VALUES (:url, :host, :timestamp, :depth)
+        ON CONFLICT (url) DO UPDATE SET
+            depth = EXCLUDED.depth
+        WHERE urls.depth > EXCLUDED.depth
+    `
+	// Enqueuing a URL again can only raise its priority.
+	SQL_INSERT_PRIORITY_URL = `
+        INSERT INTO urls (url, host, timestamp, priority)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (url) DO UPDATE SET
+            priority = EXCLUDED.priority
+        WHERE urls.priority < EXCLUDED.priority
+    `
+	SQL_DELETE_HOST_URLS = `
+        DELETE FROM urls WHERE host = $1
+    `
+	SQL_GET_URL_DEPTH = `
+        SELECT depth FROM urls WHERE url = $1
+    `
+	// Counts the URLs of a host that are queued or archived.
+	// Queued URLs without a snapshot, and URLs with one.
+	SQL_COUNT_HOST_URLS = `
+        SELECT
+            (SELECT COUNT(*) FROM urls u WHERE u.host = $1
+                AND NOT EXISTS (SELECT 1 FROM snapshots s WHERE s.url = u.url))
+            + (SELECT COUNT(DISTINCT url) FROM snapshots WHERE host = $1)
     `
 	SQL_UPDATE_URL = `
         UPDATE urls
@@ -86,16 +123,33 @@ LIMIT $1
     `
 	SQL_DELETE_URL = `
         DELETE FROM urls WHERE url=$1
+    `
+	SQL_IS_URL_QUEUED = `
+        SELECT TRUE FROM urls WHERE url = $1
+    `
+	SQL_HAS_SNAPSHOT_SINCE = `
+        SELECT TRUE FROM snapshots
+        WHERE url = $1
+        AND timestamp > $2
+        LIMIT 1
     `
 	SQL_GET_LATEST_SNAPSHOT = `
         SELECT * FROM snapshots
         WHERE url = $1
+        AND removed_at IS NULL
+        ORDER BY timestamp DESC
+        LIMIT 1
+    `
+	SQL_GET_LATEST_SIMHASH = `
+        SELECT simhash FROM snapshots
+        WHERE url = $1
         ORDER BY timestamp DESC
         LIMIT 1
     `
 	SQL_GET_SNAPSHOT_AT_TIMESTAMP = `
         SELECT * FROM snapshots
         WHERE url = $1
+        AND removed_at IS NULL
         AND timestamp <= $2
         ORDER BY timestamp DESC
         LIMIT 1
@@ -103,11 +157,13 @@ LIMIT $1
 	SQL_GET_ALL_SNAPSHOTS_FOR_URL = `
         SELECT * FROM snapshots
         WHERE url = $1
+        AND removed_at IS NULL
         ORDER BY timestamp DESC
     `
 	SQL_GET_SNAPSHOTS_BY_DATE_RANGE = `
         SELECT * FROM snapshots
         WHERE url = $1
+        AND removed_at IS NULL
         AND timestamp BETWEEN $2 AND $3
         ORDER BY timestamp DESC
     `
@@ -124,15 +180,19 @@ LIMIT $1
 	// 2. Filter to URLs with actual content and successful responses (20-29)
 	// 3. Select URLs where latest crawl is older than cutoff date
 	// 4. Rank randomly within each host and pick one URL per host
+	// URLs that moved permanently are never picked.
+	// Runs on PostgreSQL and SQLite, hence LIKE instead of a regex.
 	// Parameters: $1 = cutoff_date, $2 = limit
 	SQL_FETCH_SNAPSHOTS_FROM_HISTORY = `
 		WITH latest_attempts AS (
 			SELECT 
 				url,
 				host,
-				COALESCE(MAX(last_crawled), '1970-01-01'::timestamp) as latest_attempt
+				COALESCE(MAX(last_crawled), '1970-01-01') as latest_attempt
 			FROM snapshots
-			WHERE url ~ '^gemini://[^/]+/?$' AND mimetype = 'text/gemini' AND error IS NULL
+			WHERE url LIKE 'gemini://%' AND url NOT LIKE 'gemini://%/_%'
+				AND mimetype = 'text/gemini' AND error IS NULL
+				AND url NOT IN (SELECT url FROM canonical_urls)
 			GROUP BY url, host
 		),
 		root_urls_with_content AS (
@@ -167,4 +227,142 @@ LIMIT $1
 		ORDER BY RANDOM()
 		LIMIT $2
     `
+	SQL_GET_ROBOTS = `
+        SELECT * FROM robots
+        WHERE host_key = $1
+    `
+	SQL_UPSERT_ROBOTS = `
+        INSERT INTO robots (host_key, body, rules, status, fetched_at, expires_at)
+        VALUES (:host_key, :body, :rules, :status, :fetched_at, :expires_at)
+        ON CONFLICT (host_key) DO UPDATE SET
+            body = EXCLUDED.body,
+            rules = EXCLUDED.rules,
+            status = EXCLUDED.status,
+            fetched_at = EXCLUDED.fetched_at,
+            expires_at = EXCLUDED.expires_at
+    `
+	SQL_INSERT_ROBOTS_HISTORY = `
+        INSERT INTO robots_history (host_key, body, rules, status, changed_at)
+        VALUES (:host_key, :body, :rules, :status, :fetched_at)
+    `
+	// SQL_GET_EXPIRED_ROBOTS returns the host keys of
+	// robots.txt entries that should be refreshed, oldest first.
+	// Parameters: $1 = current time, $2 = limit
+	SQL_GET_EXPIRED_ROBOTS = `
+        SELECT host_key FROM robots
+        WHERE expires_at < $1
+        ORDER BY expires_at
+        LIMIT $2
+    `
+	SQL_INSERT_GOPHER_SEARCH = `
+        INSERT INTO gopher_searches (url, host, menu_url, query, timestamp)
+        VALUES (:url, :host, :menu_url, :query, :timestamp)
+        ON CONFLICT (url) DO UPDATE SET
+            timestamp = EXCLUDED.timestamp
+    `
+	SQL_COUNT_GOPHER_SEARCHES = `
+        SELECT COUNT(*) FROM gopher_searches
+        WHERE host = $1
+        AND timestamp > $2
+    `
+	SQL_UPSERT_INPUT_ENDPOINT = `
+        INSERT INTO input_endpoints (url, host, prompt, sensitive, first_seen, last_seen)
+        VALUES (:url, :host, :prompt, :sensitive, :seen, :seen)
+        ON CONFLICT (url) DO UPDATE SET
+            prompt = EXCLUDED.prompt,
+            sensitive = EXCLUDED.sensitive,
+            last_seen = EXCLUDED.last_seen
+    `
+	SQL_UPSERT_REDIRECT = `
+        INSERT INTO redirects (from_url, to_url, code, seen_at)
+        VALUES (:from_url, :to_url, :code, :seen_at)
+        ON CONFLICT (from_url, to_url, code) DO UPDATE SET
+            seen_at = EXCLUDED.seen_at
+    `
+	SQL_GET_CANONICAL_URL = `
+        SELECT canonical_url FROM canonical_urls
+        WHERE url = $1
+    `
+	SQL_UPSERT_CANONICAL_URL = `
+        INSERT INTO canonical_urls (url, canonical_url, updated_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (url) DO UPDATE SET
+            canonical_url = EXCLUDED.canonical_url,
+            updated_at = EXCLUDED.updated_at
+    `
+	// Points URLs that moved to $1 to where $1 moved.
+	SQL_UPDATE_CANONICAL_URLS = `
+        UPDATE canonical_urls SET canonical_url = $2, updated_at = $3
+        WHERE canonical_url = $1
+    `
+	SQL_DELETE_CANONICAL_URL = `
+        DELETE FROM canonical_urls
+        WHERE url = $1
+    `
+	SQL_UPSERT_QUARANTINED_URL = `
+        INSERT INTO quarantined_urls (url, host, source_url, reason, first_seen, last_seen)
+        VALUES (:url, :host, :source_url, :reason, :seen, :seen)
+        ON CONFLICT (url) DO UPDATE SET
+            reason = EXCLUDED.reason,
+            hits = quarantined_urls.hits + 1,
+            last_seen = EXCLUDED.last_seen
+    `
+	// $1 is a LIKE pattern, with backslash as the escape
+	// character, and $2 a host, or empty for any host
+	SQL_GET_QUEUED_URLS = `
+        SELECT url FROM urls
+        WHERE url LIKE $1 ESCAPE '\' AND ($2 = '' OR host = $2)
+    `
+	SQL_GET_SNAPSHOT_URLS = `
+        SELECT DISTINCT url FROM snapshots
+        WHERE url LIKE $1 ESCAPE '\' AND ($2 = '' OR host = $2)
+    `
+	// The batch statements below are expanded with sqlx.In
+	SQL_DELETE_URLS = `
+        DELETE FROM urls WHERE url IN (?)
+    `
+	SQL_MARK_SNAPSHOTS_REMOVED = `
+        UPDATE snapshots SET removed_at = ?
+        WHERE url IN (?) AND removed_at IS NULL
+        RETURNING url
+    `
+	SQL_DELETE_SNAPSHOTS = `
+        DELETE FROM snapshots WHERE url IN (?)
+        RETURNING url
+    `
+	SQL_INSERT_REMOVAL = `
+        INSERT INTO removal_log (url, target, action, snapshots, removed_by, reason, removed_at)
+        VALUES (:url, :target, :action, :snapshots, :removed_by, :reason, :removed_at)
+    `
+	// Clears the content of a URL's snapshots,
+	// keeping the rows as a record they existed.
+	SQL_TOMBSTONE_SNAPSHOTS = `
+        UPDATE snapshots SET
+            data = NULL,
+            gemtext = NULL,
+            links = NULL,
+            simhash = NULL,
+            removed_at = COALESCE(removed_at, ?)
+        WHERE url IN (?)
+        AND (removed_at IS NULL OR data IS NOT NULL OR gemtext IS NOT NULL)
+        RETURNING url
+    `
+	SQL_INSERT_TAKEDOWN_RULE = `
+        INSERT INTO takedown_rules (kind, pattern, reason, created_by, created_at)
+        VALUES (:kind, :pattern, :reason, :created_by, :created_at)
+        RETURNING id
+    `
+	SQL_GET_ACTIVE_TAKEDOWN_RULES = `
+        SELECT * FROM takedown_rules
+        WHERE revoked_at IS NULL
+        ORDER BY id
+    `
+	SQL_GET_ALL_TAKEDOWN_RULES = `
+        SELECT * FROM takedown_rules
+        ORDER BY id
+    `
+	SQL_REVOKE_TAKEDOWN_RULE = `
+        UPDATE takedown_rules SET revoked_at = $2
+        WHERE id = $1 AND revoked_at IS NULL
+    `
 )
@@ -0,0 +1,442 @@
+package db
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gemini-grc/common/snapshot"
+	"gemini-grc/config"
+	"github.com/guregu/null/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this file run against every DbService. PostgreSQL
+// needs an empty database created with misc/sql/initdb.sql, in
+// GEMINI_GRC_TEST_PGURL. The tests delete everything in it.
+type backend struct {
+	name string
+	open func(t *testing.T) DbService
+}
+
+func backends() []backend {
+	return []backend{
+		{"Memory", func(t *testing.T) DbService {
+			t.Helper()
+			return NewMemoryDbService(io.Discard)
+		}},
+		{"SQLite", func(t *testing.T) DbService {
+			t.Helper()
+			if !sqliteSupported {
+				t.Skip("SQLite needs cgo")
+			}
+			config.CONFIG.DbURL = "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+			return &SqliteDbService{}
+		}},
+		{"PostgreSQL", func(t *testing.T) DbService {
+			t.Helper()
+			pgURL := os.Getenv("GEMINI_GRC_TEST_PGURL")
+			if pgURL == "" {
+				t.Skip("GEMINI_GRC_TEST_PGURL not set")
+			}
+			config.CONFIG.DbURL = pgURL
+			return &DbServiceImpl{}
+		}},
+	}
+}
+
+// openBackend initializes a backend, and
+// shuts it down when the test is done.
+func openBackend(t *testing.T, b backend) DbService {
+	t.Helper()
+	previous := config.CONFIG
+	t.Cleanup(func() { config.CONFIG = previous })
+	config.CONFIG = config.Config{MaxDbConnections: 5}
+
+	ctx := context.Background()
+	d := b.open(t)
+	require.NoError(t, d.Initialize(ctx))
+	t.Cleanup(func() { _ = d.Shutdown(ctx) })
+	return d
+}
+
+// forEachBackend runs test against every backend, with an
+// empty database and a transaction that is committed after.
+func forEachBackend(t *testing.T, test func(t *testing.T, d DbService, tx *sqlx.Tx)) {
+	t.Helper()
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+			d := openBackend(t, b)
+
+			tx, err := d.NewTx(ctx)
+			require.NoError(t, err)
+			if _, ok := d.(*DbServiceImpl); ok {
+				_, err = tx.ExecContext(ctx, `TRUNCATE urls, snapshots, robots, robots_history, gopher_searches,
+					input_endpoints, redirects, canonical_urls, quarantined_urls, removal_log, takedown_rules`)
+				require.NoError(t, err)
+			}
+			test(t, d, tx)
+			require.NoError(t, tx.Commit())
+		})
+	}
+}
+
+func newTestSnapshot(t *testing.T, url string, gemtext string) *snapshot.Snapshot {
+	t.Helper()
+	s, err := snapshot.SnapshotFromURL(url, true)
+	require.NoError(t, err)
+	s.ResponseCode = null.IntFrom(20)
+	s.MimeType = null.StringFrom("text/gemini")
+	s.GemText = null.StringFrom(gemtext)
+	return s
+}
+
+func TestDbServiceQueue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		require.NoError(t, d.InsertURLWithDepth(ctx, tx, "gemini://example.org/a", 2))
+		require.NoError(t, d.InsertURLWithDepth(ctx, tx, "gemini://example.org/a", 1))
+		require.NoError(t, d.InsertURLWithDepth(ctx, tx, "gemini://example.org/a", 3))
+		require.NoError(t, d.InsertURL(ctx, tx, "gemini://example.org/b"))
+		require.NoError(t, d.InsertPriorityURL(ctx, tx, "gemini://example.org/c", 10))
+		require.NoError(t, d.InsertPriorityURL(ctx, tx, "gemini://example.org/c", 5))
+		require.NoError(t, d.InsertURL(ctx, tx, "gemini://example.com/"))
+
+		depth, err := d.GetURLDepth(ctx, tx, "gemini://example.org:1965/a")
+		require.NoError(t, err)
+		assert.Equal(t, 1, depth)
+
+		count, err := d.CountURLs(ctx, tx)
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+
+		count, err = d.CountHostURLs(ctx, tx, "example.org")
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		hosts, err := d.GetUrlHosts(ctx, tx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"example.com", "example.org"}, hosts)
+
+		urls, err := d.GetRandomUrlsFromHosts(ctx, map[string]int{"example.org": 1}, tx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gemini://example.org:1965/c"}, urls)
+
+		urls, err = d.GetRandomUrlsFromHosts(ctx, map[string]int{"example.org": 5}, tx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"gemini://example.org:1965/a", "gemini://example.org:1965/b"}, urls)
+
+		hosts, err = d.GetUrlHosts(ctx, tx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example.com"}, hosts)
+
+		require.NoError(t, d.CheckAndUpdateNormalizedURL(ctx, tx, "gemini://example.com/", "gemini://example.com:1965/"))
+		queued, err := d.IsURLQueued(ctx, tx, "gemini://example.com:1965/")
+		require.NoError(t, err)
+		assert.True(t, queued)
+
+		require.NoError(t, d.DeleteURL(ctx, tx, "gemini://example.org:1965/a"))
+		queued, err = d.IsURLQueued(ctx, tx, "gemini://example.org:1965/a")
+		require.NoError(t, err)
+		assert.False(t, queued)
+
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{Prefix: "gemini://EXAMPLE.org"})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{Prefix: "gemini://example.org:1965/"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"gemini://example.org:1965/b", "gemini://example.org:1965/c"}, urls)
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{Prefix: "gemini://example.org:1965/_"})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{Host: "example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gemini://example.com:1965/"}, urls)
+
+		deleted, err := d.DeleteHostURLs(ctx, tx, "example.org")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gemini://example.com:1965/"}, urls)
+
+		require.NoError(t, d.DeleteURLs(ctx, tx, []string{"gemini://example.com:1965/", "gemini://example.net:1965/"}))
+		urls, err = d.GetQueuedURLs(ctx, tx, URLFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+}
+
+func TestDbServiceRollback(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			if b.name == "Memory" {
+				t.Skip("The memory database has no transactions")
+			}
+			ctx := context.Background()
+			d := openBackend(t, b)
+			url := "gemini://rollback.example.org:1965/"
+
+			tx, err := d.NewTx(ctx)
+			require.NoError(t, err)
+			require.NoError(t, d.InsertURL(ctx, tx, url))
+			require.NoError(t, tx.Rollback())
+
+			tx, err = d.NewTx(ctx)
+			require.NoError(t, err)
+			queued, err := d.IsURLQueued(ctx, tx, url)
+			require.NoError(t, err)
+			assert.False(t, queued)
+			require.NoError(t, tx.Commit())
+		})
+	}
+}
+
+func TestDbServiceSnapshots(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		url := "gemini://example.org:1965/"
+		before := time.Now()
+
+		first := newTestSnapshot(t, url, "# Hello\n")
+		first.SimHash = null.IntFrom(42)
+		require.NoError(t, d.SaveSnapshot(ctx, tx, first))
+		second := newTestSnapshot(t, url, "# Hello again\n")
+		require.NoError(t, d.SaveSnapshot(ctx, tx, second))
+
+		latest, err := d.GetLatestSnapshot(ctx, tx, url)
+		require.NoError(t, err)
+		require.NotNil(t, latest)
+		assert.Equal(t, "# Hello again\n", latest.GemText.String)
+		assert.Equal(t, int64(20), latest.ResponseCode.Int64)
+		assert.Equal(t, url, latest.URL.Full)
+
+		identical, err := d.IsContentIdentical(ctx, tx, newTestSnapshot(t, url, "# Hello again\n"))
+		require.NoError(t, err)
+		assert.True(t, identical)
+		identical, err = d.IsContentIdentical(ctx, tx, first)
+		require.NoError(t, err)
+		assert.False(t, identical)
+
+		simHash, err := d.GetLatestSimHash(ctx, tx, url)
+		require.NoError(t, err)
+		assert.False(t, simHash.Valid)
+
+		snapshots, err := d.GetAllSnapshotsForURL(ctx, tx, url)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		assert.Equal(t, "# Hello\n", snapshots[1].GemText.String)
+
+		atFirst, err := d.GetSnapshotAtTimestamp(ctx, tx, url, first.Timestamp.Time)
+		require.NoError(t, err)
+		assert.Equal(t, "# Hello\n", atFirst.GemText.String)
+		_, err = d.GetSnapshotAtTimestamp(ctx, tx, url, before.Add(-time.Hour))
+		assert.Error(t, err)
+
+		inRange, err := d.GetSnapshotsByDateRange(ctx, tx, url, before.Add(-time.Minute), time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, inRange, 2)
+
+		recent, err := d.HasSnapshotSince(ctx, tx, url, before.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.True(t, recent)
+		recent, err = d.HasSnapshotSince(ctx, tx, url, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, recent)
+
+		require.NoError(t, d.UpdateLastCrawled(ctx, tx, url))
+
+		urls, err := d.GetSnapshotURLs(ctx, tx, URLFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{url}, urls)
+		urls, err = d.GetSnapshotURLs(ctx, tx, URLFilter{Prefix: "gemini://example.com"})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+		urls, err = d.GetSnapshotURLs(ctx, tx, URLFilter{Host: "example.org"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{url}, urls)
+		urls, err = d.GetSnapshotURLs(ctx, tx, URLFilter{Host: "example.com"})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+
+		marked, err := d.MarkSnapshotsRemoved(ctx, tx, []string{url, "gemini://example.com:1965/"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{url: 2}, marked)
+		latest, err = d.GetLatestSnapshot(ctx, tx, url)
+		require.NoError(t, err)
+		assert.Nil(t, latest)
+
+		tombstoned, err := d.TombstoneSnapshots(ctx, tx, []string{url})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{url: 2}, tombstoned)
+		tombstoned, err = d.TombstoneSnapshots(ctx, tx, []string{url})
+		require.NoError(t, err)
+		assert.Empty(t, tombstoned)
+
+		deleted, err := d.DeleteSnapshots(ctx, tx, []string{url})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{url: 2}, deleted)
+	})
+}
+
+func TestDbServiceGetURLsToRecrawl(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		if _, ok := d.(*MemoryDbService); ok {
+			t.Skip("The memory database has no history to recrawl")
+		}
+		ctx := context.Background()
+		for _, url := range []string{
+			"gemini://example.org/",
+			"gemini://example.org/page",
+			"gemini://moved.example.org/",
+		} {
+			require.NoError(t, d.SaveSnapshot(ctx, tx, newTestSnapshot(t, url, "# Page\n")))
+		}
+		require.NoError(t, d.SaveCanonicalURL(ctx, tx, "gemini://moved.example.org:1965/", "gemini://example.org:1965/"))
+
+		urls, err := d.GetURLsToRecrawl(ctx, tx, time.Now().Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gemini://example.org:1965/"}, urls)
+
+		urls, err = d.GetURLsToRecrawl(ctx, tx, time.Now().Add(-time.Hour), 10)
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+}
+
+func TestDbServiceCanonicalURLs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		require.NoError(t, d.SaveCanonicalURL(ctx, tx, "gemini://example.org/a", "gemini://example.org/b"))
+		require.NoError(t, d.SaveCanonicalURL(ctx, tx, "gemini://example.org/b", "gemini://example.org/c"))
+
+		canonicalURL, err := d.GetCanonicalURL(ctx, tx, "gemini://example.org/a")
+		require.NoError(t, err)
+		assert.Equal(t, "gemini://example.org/c", canonicalURL)
+
+		canonicalURL, err = d.GetCanonicalURL(ctx, tx, "gemini://example.org/c")
+		require.NoError(t, err)
+		assert.Equal(t, "gemini://example.org/c", canonicalURL)
+	})
+}
+
+func TestDbServiceRobots(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		now := time.Now()
+		for _, e := range []*RobotsEntry{
+			{HostKey: "gemini://expired.org:1965", Status: RobotsStatusOK, FetchedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour),
+				Body: null.StringFrom("User-agent: *\nDisallow: /private\n"), Rules: null.ValueFrom([]byte(`{"disallow":["/private"]}`))},
+			{HostKey: "gemini://fresh.org:1965", Status: RobotsStatusMissing, FetchedAt: now, ExpiresAt: now.Add(time.Hour)},
+		} {
+			require.NoError(t, d.SaveRobotsEntry(ctx, tx, e))
+		}
+
+		e, err := d.GetRobotsEntry(ctx, tx, "gemini://expired.org:1965")
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		assert.Equal(t, RobotsStatusOK, e.Status)
+		assert.JSONEq(t, `{"disallow":["/private"]}`, string(e.Rules.V))
+		assert.WithinDuration(t, now.Add(-time.Hour), e.ExpiresAt, time.Second)
+
+		e, err = d.GetRobotsEntry(ctx, tx, "gemini://unknown.org:1965")
+		require.NoError(t, err)
+		assert.Nil(t, e)
+
+		keys, err := d.GetExpiredRobotsKeys(ctx, tx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gemini://expired.org:1965"}, keys)
+	})
+}
+
+func TestDbServiceGopherSearches(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		for _, query := range []string{"foo", "bar", "foo"} {
+			require.NoError(t, d.InsertGopherSearch(ctx, tx, &GopherSearch{
+				URL:       "gopher://example.org:70/7/search%09" + query,
+				Host:      "example.org",
+				MenuURL:   "gopher://example.org:70/1/",
+				Query:     query,
+				Timestamp: time.Now(),
+			}))
+		}
+		require.NoError(t, d.InsertGopherSearch(ctx, tx, &GopherSearch{
+			URL:       "gopher://example.org:70/7/search%09old",
+			Host:      "example.org",
+			MenuURL:   "gopher://example.org:70/1/",
+			Query:     "old",
+			Timestamp: time.Now().Add(-48 * time.Hour),
+		}))
+
+		count, err := d.CountGopherSearches(ctx, tx, "example.org", time.Now().Add(-24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = d.CountGopherSearches(ctx, tx, "example.org", time.Now().Add(-72*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+}
+
+func TestDbServiceTakedownRules(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		hostID, err := d.AddTakedownRule(ctx, tx, &TakedownRule{
+			Kind: TakedownKindHost, Pattern: "example.org", Reason: "Asked", CreatedBy: "admin", CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+		prefixID, err := d.AddTakedownRule(ctx, tx, &TakedownRule{
+			Kind: TakedownKindPrefix, Pattern: "gemini://example.com:1965/~bob/", Reason: "Asked", CreatedBy: "admin", CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+		assert.NotEqual(t, hostID, prefixID)
+
+		revoked, err := d.RevokeTakedownRule(ctx, tx, hostID)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		revoked, err = d.RevokeTakedownRule(ctx, tx, hostID)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		rules, err := d.GetTakedownRules(ctx, tx, false)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, prefixID, rules[0].ID)
+
+		rules, err = d.GetTakedownRules(ctx, tx, true)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		assert.True(t, rules[0].RevokedAt.Valid)
+
+		require.NoError(t, d.LogRemovals(ctx, tx, []Removal{
+			{URL: "gemini://example.org:1965/a", Target: RemovalTargetQueue, Action: RemovalActionDelete, RemovedBy: "admin", Reason: "Asked", RemovedAt: time.Now()},
+			{URL: "gemini://example.org:1965/b", Target: RemovalTargetSnapshots, Action: RemovalActionMark, Snapshots: 2, RemovedBy: "admin", Reason: "Asked", RemovedAt: time.Now()},
+		}))
+	})
+}
+
+func TestDbServiceCrawlRecords(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, d DbService, tx *sqlx.Tx) {
+		ctx := context.Background()
+		now := time.Now()
+		require.NoError(t, d.SaveInputEndpoint(ctx, tx, &InputEndpoint{
+			URL: "gemini://example.org:1965/search", Host: "example.org", Prompt: "Query", Seen: now,
+		}))
+		for range 2 {
+			require.NoError(t, d.SaveRedirect(ctx, tx, &Redirect{
+				FromURL: "gemini://example.org:1965/old", ToURL: "gemini://example.org:1965/new", Code: 31, SeenAt: now,
+			}))
+			require.NoError(t, d.QuarantineURL(ctx, tx, &QuarantinedURL{
+				URL: "gemini://example.org:1965/a/a/a/a", Host: "example.org", SourceURL: "gemini://example.org:1965/a/a/a", Reason: "repeated segments", Seen: now,
+			}))
+		}
+	})
+}
@@ -0,0 +1,847 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/contextutil"
+	"gemini-grc/protocol"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/guregu/null/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// MemoryDbService keeps the queue and everything else
+// in memory, so dry runs don't need a database. Saved
+// snapshots are also written as JSON lines.
+//
+// Transactions exist for the DbService interface only:
+// changes apply right away, and rolling back doesn't
+// undo them.
+type MemoryDbService struct {
+	db  *sqlx.DB
+	out io.Writer
+
+	mu             sync.Mutex
+	urls           map[string]*memoryURL
+	snapshots      map[string][]*snapshot.Snapshot // Oldest first
+	nextSnapshotID int
+	robots         map[string]*RobotsEntry
+	gopherSearches map[string]GopherSearch // By search URL
+	canonicalURLs  map[string]string
+	takedownRules  []TakedownRule
+}
+
+type memoryURL struct {
+	host           string
+	depth          int
+	priority       int
+	beingProcessed bool
+}
+
+// SnapshotLine is the JSON line written for a saved snapshot.
+type SnapshotLine struct {
+	URL            string   `json:"url"`
+	Timestamp      string   `json:"timestamp"`
+	Code           int64    `json:"code,omitempty"`
+	MimeType       string   `json:"mimetype,omitempty"`
+	Header         string   `json:"header,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	Size           int      `json:"size"`
+	SkippedContent bool     `json:"skipped_content,omitempty"`
+	Links          []string `json:"links,omitempty"`
+}
+
+// NewMemoryDbService returns an empty memory database
+// that writes saved snapshots to out.
+func NewMemoryDbService(out io.Writer) *MemoryDbService {
+	return &MemoryDbService{
+		out:            out,
+		urls:           make(map[string]*memoryURL),
+		snapshots:      make(map[string][]*snapshot.Snapshot),
+		robots:         make(map[string]*RobotsEntry),
+		gopherSearches: make(map[string]GopherSearch),
+		canonicalURLs:  make(map[string]string),
+	}
+}
+
+func (d *MemoryDbService) Initialize(ctx context.Context) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Using the memory database")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.db != nil {
+		return nil
+	}
+	db, err := sqlx.Open(memoryDriverName, "")
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("unable to open memory database: %w", err), 0, "", true)
+	}
+	d.db = db
+	return nil
+}
+
+func (d *MemoryDbService) Shutdown(_ context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, u := range d.urls {
+		u.beingProcessed = false
+	}
+	if d.db == nil {
+		return nil
+	}
+	err := d.db.Close()
+	d.db = nil
+	return err
+}
+
+func (d *MemoryDbService) NewTx(ctx context.Context) (*sqlx.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.db.BeginTxx(ctx, nil)
+}
+
+func (d *MemoryDbService) InsertURL(ctx context.Context, tx *sqlx.Tx, url string) error {
+	return d.InsertURLWithDepth(ctx, tx, url, 0)
+}
+
+func (d *MemoryDbService) InsertURLWithDepth(ctx context.Context, _ *sqlx.Tx, url string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	normalizedURL, err := commonUrl.ParseURL(url, "", true)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if u, ok := d.urls[normalizedURL.Full]; ok {
+		u.depth = min(u.depth, depth)
+		return nil
+	}
+	d.urls[normalizedURL.Full] = &memoryURL{host: normalizedURL.Hostname, depth: depth}
+	return nil
+}
+
+func (d *MemoryDbService) InsertPriorityURL(ctx context.Context, _ *sqlx.Tx, url string, priority int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	normalizedURL, err := commonUrl.ParseURL(url, "", true)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if u, ok := d.urls[normalizedURL.Full]; ok {
+		u.priority = max(u.priority, priority)
+		return nil
+	}
+	d.urls[normalizedURL.Full] = &memoryURL{host: normalizedURL.Hostname, priority: priority}
+	return nil
+}
+
+func (d *MemoryDbService) DeleteHostURLs(ctx context.Context, _ *sqlx.Tx, host string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var deleted int64
+	for url, u := range d.urls {
+		if u.host == host {
+			delete(d.urls, url)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (d *MemoryDbService) GetURLDepth(ctx context.Context, _ *sqlx.Tx, url string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if u, ok := d.urls[url]; ok {
+		return u.depth, nil
+	}
+	return 0, nil
+}
+
+func (d *MemoryDbService) CountHostURLs(ctx context.Context, _ *sqlx.Tx, host string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := 0
+	for _, u := range d.urls {
+		if u.host == host {
+			count++
+		}
+	}
+	for url, snapshots := range d.snapshots {
+		if _, queued := d.urls[url]; !queued && snapshots[0].Host == host {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *MemoryDbService) CheckAndUpdateNormalizedURL(ctx context.Context, _ *sqlx.Tx, url string, normalizedURL string) error {
+	if url == normalizedURL {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u, ok := d.urls[url]
+	if _, exists := d.urls[normalizedURL]; !ok || exists {
+		return nil
+	}
+	delete(d.urls, url)
+	d.urls[normalizedURL] = u
+	return nil
+}
+
+func (d *MemoryDbService) DeleteURL(ctx context.Context, _ *sqlx.Tx, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.urls, url)
+	return nil
+}
+
+func (d *MemoryDbService) IsURLQueued(ctx context.Context, _ *sqlx.Tx, url string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.urls[url]
+	return ok, nil
+}
+
+func (d *MemoryDbService) MarkURLsAsBeingProcessed(ctx context.Context, _ *sqlx.Tx, urls []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, url := range urls {
+		if u, ok := d.urls[url]; ok {
+			u.beingProcessed = true
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDbService) GetUrlHosts(ctx context.Context, _ *sqlx.Tx) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var hosts []string
+	for url, u := range d.urls {
+		if !u.beingProcessed && protocol.IsEnabled(url) && !slices.Contains(hosts, u.host) {
+			hosts = append(hosts, u.host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+func (d *MemoryDbService) CountURLs(ctx context.Context, _ *sqlx.Tx) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := 0
+	for url := range d.urls {
+		if protocol.IsEnabled(url) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *MemoryDbService) GetRandomUrlsFromHosts(ctx context.Context, hostLimits map[string]int, _ *sqlx.Tx) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var urls []string
+	for host, limit := range hostLimits {
+		var candidates []string
+		for url, u := range d.urls {
+			if u.host == host && !u.beingProcessed && protocol.IsEnabled(url) {
+				candidates = append(candidates, url)
+			}
+		}
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return d.urls[candidates[i]].priority > d.urls[candidates[j]].priority
+		})
+		if len(candidates) > limit {
+			candidates = candidates[:limit]
+		}
+		for _, url := range candidates {
+			d.urls[url].beingProcessed = true
+		}
+		urls = append(urls, candidates...)
+	}
+	return urls, nil
+}
+
+func (d *MemoryDbService) SaveSnapshot(ctx context.Context, _ *sqlx.Tx, s *snapshot.Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	currentTime := time.Now()
+	s.Timestamp = null.TimeFrom(currentTime)
+	s.LastCrawled = null.TimeFrom(currentTime)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextSnapshotID++
+	s.ID = d.nextSnapshotID
+	saved := *s
+	d.snapshots[s.URL.Full] = append(d.snapshots[s.URL.Full], &saved)
+
+	line := SnapshotLine{
+		URL:            s.URL.Full,
+		Timestamp:      currentTime.Format(time.RFC3339),
+		Code:           s.ResponseCode.ValueOrZero(),
+		MimeType:       s.MimeType.ValueOrZero(),
+		Header:         s.Header.ValueOrZero(),
+		Error:          s.Error.ValueOrZero(),
+		Size:           len(s.Data.V) + len(s.GemText.String),
+		SkippedContent: s.SkippedContent,
+	}
+	for _, link := range s.Links.ValueOrZero() {
+		line.Links = append(line.Links, link.Full)
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("JSON serialization error for %s: %w", s.URL.Full, err), 0, "", true)
+	}
+	_, err = fmt.Fprintln(d.out, string(data))
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("cannot write snapshot of %s: %w", s.URL.Full, err), 0, "", true)
+	}
+	return nil
+}
+
+func (d *MemoryDbService) OverwriteSnapshot(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) error {
+	return d.SaveSnapshot(ctx, tx, s)
+}
+
+func (d *MemoryDbService) UpdateLastCrawled(ctx context.Context, _ *sqlx.Tx, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if snapshots := d.snapshots[url]; len(snapshots) > 0 {
+		snapshots[len(snapshots)-1].LastCrawled = null.TimeFrom(time.Now())
+	}
+	return nil
+}
+
+// archived returns the snapshots of a URL that
+// aren't removed from the archive, newest first.
+func (d *MemoryDbService) archived(url string) []*snapshot.Snapshot {
+	var snapshots []*snapshot.Snapshot
+	for _, s := range slices.Backward(d.snapshots[url]) {
+		if !s.RemovedAt.Valid {
+			copied := *s
+			snapshots = append(snapshots, &copied)
+		}
+	}
+	return snapshots
+}
+
+func (d *MemoryDbService) GetLatestSnapshot(ctx context.Context, _ *sqlx.Tx, url string) (*snapshot.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshots := d.archived(url)
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	return snapshots[0], nil
+}
+
+func (d *MemoryDbService) GetSnapshotAtTimestamp(ctx context.Context, _ *sqlx.Tx, url string, timestamp time.Time) (*snapshot.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.archived(url) {
+		if !s.Timestamp.Time.After(timestamp) {
+			return s, nil
+		}
+	}
+	return nil, xerrors.NewError(fmt.Errorf("no snapshot found for URL %s at or before %v", url, timestamp), 0, "", false)
+}
+
+func (d *MemoryDbService) GetAllSnapshotsForURL(ctx context.Context, _ *sqlx.Tx, url string) ([]*snapshot.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshots := d.archived(url)
+	if snapshots == nil {
+		snapshots = []*snapshot.Snapshot{}
+	}
+	return snapshots, nil
+}
+
+func (d *MemoryDbService) GetSnapshotsByDateRange(ctx context.Context, _ *sqlx.Tx, url string, startTime, endTime time.Time) ([]*snapshot.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshots := []*snapshot.Snapshot{}
+	for _, s := range d.archived(url) {
+		if !s.Timestamp.Time.Before(startTime) && !s.Timestamp.Time.After(endTime) {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return snapshots, nil
+}
+
+func (d *MemoryDbService) IsContentIdentical(ctx context.Context, tx *sqlx.Tx, s *snapshot.Snapshot) (bool, error) {
+	latestSnapshot, err := d.GetLatestSnapshot(ctx, tx, s.URL.String())
+	if err != nil || latestSnapshot == nil {
+		return false, err
+	}
+	return contentIdentical(s, latestSnapshot), nil
+}
+
+func (d *MemoryDbService) GetLatestSimHash(ctx context.Context, _ *sqlx.Tx, url string) (null.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return null.Int{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshots := d.snapshots[url]
+	if len(snapshots) == 0 {
+		return null.Int{}, nil
+	}
+	return snapshots[len(snapshots)-1].SimHash, nil
+}
+
+func (d *MemoryDbService) HasSnapshotSince(ctx context.Context, _ *sqlx.Tx, url string, since time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.snapshots[url] {
+		if s.Timestamp.Time.After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// The memory database only lives for one crawl,
+// so none of its URLs are due for a recrawl.
+func (d *MemoryDbService) GetURLsToRecrawl(ctx context.Context, _ *sqlx.Tx, _ time.Time, _ int) ([]string, error) {
+	return nil, ctx.Err()
+}
+
+func (d *MemoryDbService) GetRobotsEntry(ctx context.Context, _ *sqlx.Tx, hostKey string) (*RobotsEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.robots[hostKey]
+	if !ok {
+		return nil, nil
+	}
+	copied := *e
+	return &copied, nil
+}
+
+func (d *MemoryDbService) SaveRobotsEntry(ctx context.Context, _ *sqlx.Tx, e *RobotsEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := *e
+	d.robots[e.HostKey] = &copied
+	return nil
+}
+
+func (d *MemoryDbService) GetExpiredRobotsKeys(ctx context.Context, _ *sqlx.Tx, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var keys []string
+	now := time.Now()
+	for key, e := range d.robots {
+		if e.ExpiresAt.Before(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return d.robots[keys[i]].ExpiresAt.Before(d.robots[keys[j]].ExpiresAt)
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+func (d *MemoryDbService) InsertGopherSearch(ctx context.Context, _ *sqlx.Tx, search *GopherSearch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gopherSearches[search.URL] = *search
+	return nil
+}
+
+func (d *MemoryDbService) CountGopherSearches(ctx context.Context, _ *sqlx.Tx, host string, since time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := 0
+	for _, search := range d.gopherSearches {
+		if search.Host == host && search.Timestamp.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Input endpoints, redirects and quarantined URLs are
+// only ever written by the crawler, and are logged as
+// it finds them, so the memory database drops them.
+
+func (d *MemoryDbService) SaveInputEndpoint(ctx context.Context, _ *sqlx.Tx, _ *InputEndpoint) error {
+	return ctx.Err()
+}
+
+func (d *MemoryDbService) SaveRedirect(ctx context.Context, _ *sqlx.Tx, _ *Redirect) error {
+	return ctx.Err()
+}
+
+func (d *MemoryDbService) QuarantineURL(ctx context.Context, _ *sqlx.Tx, _ *QuarantinedURL) error {
+	return ctx.Err()
+}
+
+func (d *MemoryDbService) GetCanonicalURL(ctx context.Context, _ *sqlx.Tx, url string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if canonicalURL, ok := d.canonicalURLs[url]; ok {
+		return canonicalURL, nil
+	}
+	return url, nil
+}
+
+// SaveCanonicalURL keeps mappings one level
+// deep, like DbServiceImpl.SaveCanonicalURL.
+func (d *MemoryDbService) SaveCanonicalURL(ctx context.Context, _ *sqlx.Tx, url string, canonicalURL string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resolved := canonicalURL
+	if target, ok := d.canonicalURLs[canonicalURL]; ok {
+		resolved = target
+	}
+	if resolved == url {
+		delete(d.canonicalURLs, canonicalURL)
+		resolved = canonicalURL
+	}
+	d.canonicalURLs[url] = resolved
+	for from, to := range d.canonicalURLs {
+		if to == url {
+			d.canonicalURLs[from] = resolved
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDbService) GetQueuedURLs(ctx context.Context, _ *sqlx.Tx, f URLFilter) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var urls []string
+	for url, u := range d.urls {
+		if strings.HasPrefix(url, f.Prefix) && (f.Host == "" || u.host == f.Host) {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func (d *MemoryDbService) GetSnapshotURLs(ctx context.Context, _ *sqlx.Tx, f URLFilter) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var urls []string
+	for url, snapshots := range d.snapshots {
+		if strings.HasPrefix(url, f.Prefix) && (f.Host == "" || snapshots[0].Host == f.Host) {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func (d *MemoryDbService) DeleteURLs(ctx context.Context, _ *sqlx.Tx, urls []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, url := range urls {
+		delete(d.urls, url)
+	}
+	return nil
+}
+
+func (d *MemoryDbService) MarkSnapshotsRemoved(ctx context.Context, _ *sqlx.Tx, urls []string) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	marked := make(map[string]int64)
+	for _, url := range urls {
+		for _, s := range d.snapshots[url] {
+			if !s.RemovedAt.Valid {
+				s.RemovedAt = null.TimeFrom(time.Now())
+				marked[url]++
+			}
+		}
+	}
+	return marked, nil
+}
+
+func (d *MemoryDbService) DeleteSnapshots(ctx context.Context, _ *sqlx.Tx, urls []string) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deleted := make(map[string]int64)
+	for _, url := range urls {
+		if n := len(d.snapshots[url]); n > 0 {
+			deleted[url] = int64(n)
+		}
+		delete(d.snapshots, url)
+	}
+	return deleted, nil
+}
+
+func (d *MemoryDbService) LogRemovals(ctx context.Context, _ *sqlx.Tx, rs []Removal) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		contextlog.LogInfoWithContext(dbCtx, logging.GetSlogger(), "Removed %s from %s (%s): %s", r.URL, r.Target, r.Action, r.Reason)
+	}
+	return nil
+}
+
+func (d *MemoryDbService) AddTakedownRule(ctx context.Context, _ *sqlx.Tx, r *TakedownRule) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rule := *r
+	rule.ID = len(d.takedownRules) + 1
+	d.takedownRules = append(d.takedownRules, rule)
+	return rule.ID, nil
+}
+
+func (d *MemoryDbService) GetTakedownRules(ctx context.Context, _ *sqlx.Tx, includeRevoked bool) ([]TakedownRule, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var rules []TakedownRule
+	for _, r := range d.takedownRules {
+		if includeRevoked || !r.RevokedAt.Valid {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (d *MemoryDbService) RevokeTakedownRule(ctx context.Context, _ *sqlx.Tx, id int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.takedownRules {
+		if d.takedownRules[i].ID == id && !d.takedownRules[i].RevokedAt.Valid {
+			d.takedownRules[i].RevokedAt = null.TimeFrom(time.Now())
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *MemoryDbService) TombstoneSnapshots(ctx context.Context, _ *sqlx.Tx, urls []string) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tombstoned := make(map[string]int64)
+	for _, url := range urls {
+		for _, s := range d.snapshots[url] {
+			if s.RemovedAt.Valid && !s.Data.Valid && !s.GemText.Valid {
+				continue
+			}
+			s.Data = null.Value[[]byte]{}
+			s.GemText = null.String{}
+			s.Links = null.Value[linkList.LinkList]{}
+			s.SimHash = null.Int{}
+			if !s.RemovedAt.Valid {
+				s.RemovedAt = null.TimeFrom(time.Now())
+			}
+			tombstoned[url]++
+		}
+	}
+	return tombstoned, nil
+}
+
+const memoryDriverName = "gemini-grc-memory"
+
+func init() {
+	sql.Register(memoryDriverName, memoryDriver{})
+}
+
+// memoryDriver is a database/sql driver that can only
+// begin and end transactions, so MemoryDbService can
+// hand out the *sqlx.Tx values DbService works with.
+type memoryDriver struct{}
+
+type memoryConn struct{}
+
+type memoryTx struct{}
+
+var memoryQueryRegex = regexp.MustCompile(`\s+`)
+
+func (memoryDriver) Open(string) (driver.Conn, error) {
+	return memoryConn{}, nil
+}
+
+func (memoryConn) Prepare(query string) (driver.Stmt, error) {
+	query = strings.TrimSpace(memoryQueryRegex.ReplaceAllString(query, " "))
+	return nil, fmt.Errorf("the memory database can't run SQL: %s", query)
+}
+
+func (memoryConn) Close() error {
+	return nil
+}
+
+func (memoryConn) Begin() (driver.Tx, error) {
+	return memoryTx{}, nil
+}
+
+func (memoryTx) Commit() error {
+	return nil
+}
+
+func (memoryTx) Rollback() error {
+	return nil
+}
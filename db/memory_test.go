@@ -0,0 +1,153 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"github.com/guregu/null/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryDb(t *testing.T) (*MemoryDbService, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	d := NewMemoryDbService(&out)
+	require.NoError(t, d.Initialize(context.Background()))
+	t.Cleanup(func() { _ = d.Shutdown(context.Background()) })
+	return d, &out
+}
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	d, _ := newMemoryDb(t)
+	tx, err := d.NewTx(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, d.InsertURLWithDepth(ctx, tx, "gemini://example.org/a", 2))
+	require.NoError(t, d.InsertURLWithDepth(ctx, tx, "gemini://example.org/a", 1))
+	require.NoError(t, d.InsertURL(ctx, tx, "gemini://example.org/b"))
+	require.NoError(t, d.InsertPriorityURL(ctx, tx, "gemini://example.org/c", 10))
+	require.NoError(t, d.InsertURL(ctx, tx, "gemini://example.com/"))
+	require.NoError(t, tx.Commit())
+
+	depth, err := d.GetURLDepth(ctx, nil, "gemini://example.org:1965/a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, depth)
+
+	count, err := d.CountURLs(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	hosts, err := d.GetUrlHosts(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, hosts)
+
+	urls, err := d.GetRandomUrlsFromHosts(ctx, map[string]int{"example.org": 1}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini://example.org:1965/c"}, urls)
+
+	urls, err = d.GetRandomUrlsFromHosts(ctx, map[string]int{"example.org": 5}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gemini://example.org:1965/a", "gemini://example.org:1965/b"}, urls)
+
+	hosts, err = d.GetUrlHosts(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, hosts)
+
+	require.NoError(t, d.DeleteURL(ctx, nil, "gemini://example.org:1965/a"))
+	queued, err := d.IsURLQueued(ctx, nil, "gemini://example.org:1965/a")
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	deleted, err := d.DeleteHostURLs(ctx, nil, "example.org")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
+
+func TestMemorySaveSnapshot(t *testing.T) {
+	ctx := context.Background()
+	d, out := newMemoryDb(t)
+
+	s, err := snapshot.SnapshotFromURL("gemini://example.org/", true)
+	require.NoError(t, err)
+	link, err := commonUrl.ParseURL("gemini://example.org/about", "About", true)
+	require.NoError(t, err)
+	s.ResponseCode = null.IntFrom(20)
+	s.MimeType = null.StringFrom("text/gemini")
+	s.GemText = null.StringFrom("# Hello\n=> /about About\n")
+	s.Links = null.ValueFrom(linkList.LinkList{*link})
+	require.NoError(t, d.SaveSnapshot(ctx, nil, s))
+
+	var line SnapshotLine
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "gemini://example.org:1965/", line.URL)
+	assert.Equal(t, int64(20), line.Code)
+	assert.Equal(t, "text/gemini", line.MimeType)
+	assert.Equal(t, len(s.GemText.String), line.Size)
+	assert.Equal(t, []string{"gemini://example.org:1965/about"}, line.Links)
+
+	latest, err := d.GetLatestSnapshot(ctx, nil, s.URL.Full)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, s.GemText, latest.GemText)
+
+	identical, err := d.IsContentIdentical(ctx, nil, s)
+	require.NoError(t, err)
+	assert.True(t, identical)
+
+	tombstoned, err := d.TombstoneSnapshots(ctx, nil, []string{s.URL.Full})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{s.URL.Full: 1}, tombstoned)
+	latest, err = d.GetLatestSnapshot(ctx, nil, s.URL.Full)
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+}
+
+func TestMemoryGopherSearches(t *testing.T) {
+	ctx := context.Background()
+	d, _ := newMemoryDb(t)
+
+	for _, query := range []string{"foo", "bar", "foo"} {
+		require.NoError(t, d.InsertGopherSearch(ctx, nil, &GopherSearch{
+			URL:       "gopher://example.org:70/7/search%09" + query,
+			Host:      "example.org",
+			MenuURL:   "gopher://example.org:70/1/",
+			Query:     query,
+			Timestamp: time.Now(),
+		}))
+	}
+
+	count, err := d.CountGopherSearches(ctx, nil, "example.org", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = d.CountGopherSearches(ctx, nil, "example.org", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMemoryRollbackKeepsChanges(t *testing.T) {
+	ctx := context.Background()
+	d, _ := newMemoryDb(t)
+
+	tx, err := d.NewTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, d.InsertURL(ctx, tx, "gemini://example.org/"))
+	require.NoError(t, SafeRollback(ctx, tx))
+
+	queued, err := d.IsURLQueued(ctx, nil, "gemini://example.org:1965/")
+	require.NoError(t, err)
+	assert.True(t, queued)
+
+	tx, err = d.NewTx(ctx)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM urls")
+	assert.ErrorContains(t, err, "can't run SQL")
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/config"
+	"gemini-grc/contextutil"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/jmoiron/sqlx"
+)
+
+// SqliteDbService stores everything in an SQLite file,
+// for crawls that don't need a PostgreSQL server. It runs
+// the queries of DbServiceImpl, which are written to work
+// on both, through sqliteDriver.
+//
+// SQLite allows one writer at a time, so the database
+// has a single connection, and transactions wait for
+// each other. Since workers keep their transaction open
+// while fetching, they crawl one URL at a time.
+//
+// The driver needs cgo. Builds without it compile, but
+// Initialize fails.
+type SqliteDbService struct {
+	DbServiceImpl
+}
+
+// IsSqliteURL checks if a database URL is an SQLite one.
+func IsSqliteURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "sqlite://")
+}
+
+// ForURL returns the database of a URL:
+// SQLite for sqlite://path/to/file.db,
+// PostgreSQL otherwise.
+func ForURL(dbURL string) DbService {
+	if IsSqliteURL(dbURL) {
+		return &SqliteDbService{}
+	}
+	return &DbServiceImpl{}
+}
+
+func (d *SqliteDbService) Initialize(ctx context.Context) error {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Initializing SQLite database")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.connected {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !sqliteSupported {
+		return xerrors.NewError(fmt.Errorf("SQLite needs a build with cgo enabled"), 0, "", true)
+	}
+
+	path := strings.TrimPrefix(config.CONFIG.DatabaseURL(), "sqlite://")
+	if path == "" {
+		return xerrors.NewError(fmt.Errorf("no file in SQLite database URL %s", config.CONFIG.DatabaseURL()), 0, "", true)
+	}
+	db, err := sqlx.Open(sqliteDriverName, fmt.Sprintf("file:%s?_busy_timeout=10000&_journal_mode=WAL&_case_sensitive_like=true&_txlock=immediate", path))
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("unable to open SQLite database %s: %w", path, err), 0, "", true)
+	}
+	// A single writer. Transactions take the write lock
+	// when they begin, waiting for other processes
+	// for up to the busy timeout.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, SQL_CREATE_SQLITE_SCHEMA)
+	if err != nil {
+		_ = db.Close()
+		return xerrors.NewError(fmt.Errorf("unable to create schema of SQLite database %s: %w", path, err), 0, "", true)
+	}
+
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "SQLite database %s initialized successfully", path)
+	d.db = db
+	d.connected = true
+	return nil
+}
+
+// GetRandomUrlsFromHosts picks and marks the URLs
+// in one statement, since SQLite has no FOR UPDATE.
+func (d *SqliteDbService) GetRandomUrlsFromHosts(ctx context.Context, hostLimits map[string]int, tx *sqlx.Tx) ([]string, error) {
+	dbCtx := contextutil.ContextWithComponent(ctx, "database")
+	contextlog.LogDebugWithContext(dbCtx, logging.GetSlogger(), "Getting random URLs from %d hosts", len(hostLimits))
+
+	// Check if the context is cancelled before proceeding
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	query := fmt.Sprintf(SQL_TAKE_RANDOM_HOST_URLS, URLSchemeFilter())
+	for host, limit := range hostLimits {
+		var results []string
+		err := tx.SelectContext(ctx, &results, query, host, limit)
+		if err != nil {
+			return nil, xerrors.NewError(err, 0, "", true)
+		}
+		urls = append(urls, results...)
+	}
+	return urls, nil
+}
+
+const sqliteDriverName = "gemini-grc-sqlite"
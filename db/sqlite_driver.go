@@ -0,0 +1,89 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The SQLite driver needs cgo.
+const sqliteSupported = true
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{})
+	sqlx.BindDriver(sqliteDriverName, sqlx.QUESTION)
+}
+
+// sqliteDriver wraps the SQLite driver, so that it runs the
+// PostgreSQL flavored queries of DbServiceImpl: $1 becomes
+// ?1, and times are stored in UTC, so that they compare
+// correctly as text.
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+type sqliteConn struct {
+	conn *sqlite3.SQLiteConn
+}
+
+var sqlitePlaceholderRegex = regexp.MustCompile(`\$(\d+)`)
+
+func sqliteQuery(query string) string {
+	return sqlitePlaceholderRegex.ReplaceAllString(query, "?$1")
+}
+
+func sqliteArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			args[i].Value = t.UTC()
+		}
+	}
+	return args
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(sqliteQuery(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.PrepareContext(ctx, sqliteQuery(query))
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, sqliteQuery(query), sqliteArgs(args))
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, sqliteQuery(query), sqliteArgs(args))
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *sqliteConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package db
+
+// The SQLite driver needs cgo, so builds
+// without it can't open SQLite databases.
+const sqliteSupported = false
@@ -0,0 +1,155 @@
+package db
+
+// SQL_CREATE_SQLITE_SCHEMA creates the tables and indexes of
+// misc/sql/initdb.sql in an SQLite database, if missing.
+// Keep the two in sync.
+const SQL_CREATE_SQLITE_SCHEMA = `
+CREATE TABLE IF NOT EXISTS urls (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    host TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    being_processed BOOLEAN,
+    depth INTEGER NOT NULL DEFAULT 0,
+    priority INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS urls_url_key ON urls (url);
+CREATE INDEX IF NOT EXISTS idx_urls_url ON urls (url);
+CREATE INDEX IF NOT EXISTS idx_urls_timestamp ON urls (timestamp);
+CREATE INDEX IF NOT EXISTS idx_being_processed ON urls (being_processed);
+CREATE INDEX IF NOT EXISTS idx_urls_host ON urls (host);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    host TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    mimetype TEXT,
+    data BLOB,
+    gemtext TEXT,
+    links BLOB,
+    lang TEXT,
+    response_code INTEGER,
+    error TEXT,
+    header TEXT,
+    last_crawled TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    simhash INTEGER,
+    removed_at TIMESTAMP,
+    skipped_content BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_url_timestamp ON snapshots (url, timestamp);
+CREATE INDEX IF NOT EXISTS idx_snapshots_simhash ON snapshots (simhash);
+CREATE INDEX IF NOT EXISTS idx_url ON snapshots (url);
+CREATE INDEX IF NOT EXISTS idx_timestamp ON snapshots (timestamp);
+CREATE INDEX IF NOT EXISTS idx_mimetype ON snapshots (mimetype);
+CREATE INDEX IF NOT EXISTS idx_lang ON snapshots (lang);
+CREATE INDEX IF NOT EXISTS idx_response_code ON snapshots (response_code);
+CREATE INDEX IF NOT EXISTS idx_error ON snapshots (error);
+CREATE INDEX IF NOT EXISTS idx_host ON snapshots (host);
+CREATE INDEX IF NOT EXISTS idx_response_code_error ON snapshots (response_code, error);
+CREATE INDEX IF NOT EXISTS idx_response_code_error_nulls ON snapshots (response_code, error) WHERE response_code IS NULL AND error IS NULL;
+CREATE INDEX IF NOT EXISTS idx_snapshots_unprocessed ON snapshots (host) WHERE response_code IS NULL AND error IS NULL;
+CREATE INDEX IF NOT EXISTS idx_url_latest ON snapshots (url, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_last_crawled ON snapshots (last_crawled);
+CREATE INDEX IF NOT EXISTS idx_url_last_crawled ON snapshots (url, last_crawled DESC);
+
+CREATE TABLE IF NOT EXISTS robots (
+    host_key TEXT PRIMARY KEY,
+    body TEXT,
+    rules BLOB,
+    status TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_robots_expires_at ON robots (expires_at);
+
+CREATE TABLE IF NOT EXISTS robots_history (
+    id INTEGER PRIMARY KEY,
+    host_key TEXT NOT NULL,
+    body TEXT,
+    rules BLOB,
+    status TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_robots_history_host_key ON robots_history (host_key, changed_at DESC);
+
+CREATE TABLE IF NOT EXISTS gopher_searches (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    menu_url TEXT NOT NULL,
+    query TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gopher_searches_host ON gopher_searches (host);
+CREATE INDEX IF NOT EXISTS idx_gopher_searches_menu_url ON gopher_searches (menu_url);
+
+CREATE TABLE IF NOT EXISTS input_endpoints (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_input_endpoints_host ON input_endpoints (host);
+
+CREATE TABLE IF NOT EXISTS redirects (
+    id INTEGER PRIMARY KEY,
+    from_url TEXT NOT NULL,
+    to_url TEXT NOT NULL,
+    code INTEGER NOT NULL,
+    seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS redirects_edge_key ON redirects (from_url, to_url, code);
+CREATE INDEX IF NOT EXISTS idx_redirects_to_url ON redirects (to_url);
+
+CREATE TABLE IF NOT EXISTS canonical_urls (
+    url TEXT PRIMARY KEY,
+    canonical_url TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_canonical_urls_canonical_url ON canonical_urls (canonical_url);
+
+CREATE TABLE IF NOT EXISTS quarantined_urls (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    source_url TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 1,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantined_urls_host ON quarantined_urls (host);
+
+CREATE TABLE IF NOT EXISTS removal_log (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    target TEXT NOT NULL,
+    action TEXT NOT NULL,
+    snapshots INTEGER NOT NULL DEFAULT 0,
+    removed_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    removed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_removal_log_url ON removal_log (url);
+
+CREATE TABLE IF NOT EXISTS takedown_rules (
+    id INTEGER PRIMARY KEY,
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+`
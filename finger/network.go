@@ -0,0 +1,95 @@
+package finger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	stdurl "net/url"
+	"strings"
+
+	"gemini-grc/common/plaintext"
+	"gemini-grc/common/snapshot"
+	"gemini-grc/contextutil"
+	"gemini-grc/gemini"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/guregu/null/v5"
+)
+
+// References:
+// RFC 1288 https://www.rfc-editor.org/rfc/rfc1288.html
+
+// Finger queries go to port 79 and are a single
+// line with the user name, or an empty line to
+// list the users of a host. The response is plain
+// text until the server closes the connection.
+// We normalize finger://user@host URLs to
+// finger://host/user, so the user is the path.
+
+// Visit queries the user of a finger:// URL,
+// and returns a snapshot with the plan as text.
+// Network errors are stored in the snapshot; an
+// error is returned only when a snapshot could
+// not be constructed.
+func Visit(ctx context.Context, url string) (s *snapshot.Snapshot, err error) {
+	fingerCtx := contextutil.ContextWithComponent(ctx, "finger")
+
+	s, err = snapshot.SnapshotFromURL(url, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	data, err := ConnectAndGetData(fingerCtx, s.URL.String())
+	if err != nil {
+		s.Error = null.StringFrom(err.Error())
+		return s, nil
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	// Plans are text, but not always UTF-8.
+	text, err := gemini.BytesToValidUTF8(data)
+	if err != nil {
+		s.Error = null.StringFrom(err.Error())
+		return s, nil
+	}
+	s.Data = null.ValueFrom([]byte(text))
+	s.MimeType = null.StringFrom("text/plain")
+
+	return s, nil
+}
+
+// ConnectAndGetData sends a finger query for the
+// given URL and returns the raw response.
+func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
+	parsedURL, err := stdurl.Parse(url)
+	if err != nil {
+		return nil, xerrors.NewSimpleError(fmt.Errorf("error parsing URL: %w", err))
+	}
+
+	port := parsedURL.Port()
+	if port == "" {
+		port = "79"
+	}
+	host := net.JoinHostPort(parsedURL.Hostname(), port)
+	return plaintext.Fetch(ctx, host, []byte(fmt.Sprintf("%s\r\n", userFromURL(parsedURL))))
+}
+
+// userFromURL returns the user to query for. Both
+// finger://user@host and finger://host/user work.
+// Queries are a single line, so line breaks in
+// the user are dropped.
+func userFromURL(u *stdurl.URL) string {
+	user := strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		user = u.User.Username()
+	}
+	return strings.NewReplacer("\r", "", "\n", "").Replace(user)
+}
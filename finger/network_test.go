@@ -0,0 +1,79 @@
+package finger
+
+import (
+	"bufio"
+	"context"
+	"net"
+	stdurl "net/url"
+	"strings"
+	"testing"
+
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserFromURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"finger://example.com", ""},
+		{"finger://example.com/", ""},
+		{"finger://example.com/alice", "alice"},
+		{"finger://alice@example.com", "alice"},
+		{"finger://example.com/alice%0D%0Abob", "alicebob"},
+	}
+
+	for _, tt := range tests {
+		u, err := stdurl.Parse(tt.url)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, userFromURL(u), tt.url)
+	}
+}
+
+func TestVisit(t *testing.T) {
+	plans := map[string]string{
+		"alice": "Login: alice\r\nPlan:\r\nFinish the crawler.\r\n",
+		"huge":  strings.Repeat("x", 2048),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			_, _ = conn.Write([]byte(plans[strings.TrimSpace(line)]))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	s, err := Visit(ctx, "finger://alice@"+address)
+	assert.NoError(t, err)
+	assert.False(t, s.Error.Valid)
+	assert.Equal(t, "finger://"+address+"/alice", s.URL.Full)
+	assert.Equal(t, "text/plain", s.MimeType.ValueOrZero())
+	assert.Equal(t, plans["alice"], string(s.Data.ValueOrZero()))
+
+	s, err = Visit(ctx, "finger://"+address+"/huge")
+	assert.NoError(t, err)
+	assert.Contains(t, s.Error.ValueOrZero(), "response too large")
+}
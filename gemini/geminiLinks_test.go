@@ -22,6 +22,19 @@ var data = []TestData{
 		value:      nil,
 		error:      "error parsing link line",
 	},
+	{
+		currentURL: "gemini://smol.gr/",
+		link:       "=> finger://alice@smol.gr My plan",
+		value: &url.URL{
+			Protocol: "finger",
+			Hostname: "smol.gr",
+			Port:     79,
+			Path:     "/alice",
+			Descr:    "My plan",
+			Full:     "finger://smol.gr:79/alice",
+		},
+		error: "",
+	},
 	{
 		currentURL: "gemini://gemi.dev/cgi-bin/xkcd/",
 		link:       "=> archive/ Complete Archive",
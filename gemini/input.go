@@ -0,0 +1,46 @@
+package gemini
+
+import (
+	"net/url"
+	"strings"
+
+	"gemini-grc/common/snapshot"
+	_url "gemini-grc/common/url"
+)
+
+// Status codes of input requests.
+// With 11 the input is sensitive,
+// like a password, and is not echoed.
+const (
+	StatusInput          = 10
+	StatusSensitiveInput = 11
+)
+
+// InputPrompt returns the prompt of a Gemini
+// response asking for input, if it is one.
+func InputPrompt(s *snapshot.Snapshot) (prompt string, sensitive bool, ok bool) {
+	if !_url.IsGeminiUrl(s.URL.String()) {
+		return "", false, false
+	}
+	code := s.ResponseCode.ValueOrZero()
+	if code != StatusInput && code != StatusSensitiveInput {
+		return "", false, false
+	}
+	_, prompt, _ = strings.Cut(s.Header.ValueOrZero(), " ")
+	return strings.TrimSpace(prompt), code == StatusSensitiveInput, true
+}
+
+// InputEndpoint returns the input endpoint
+// of a URL, i.e. the URL without its query.
+func InputEndpoint(u string) string {
+	base, _, _ := strings.Cut(u, "?")
+	base, _, _ = strings.Cut(base, "#")
+	return base
+}
+
+// InputURL returns the URL that submits
+// the given input to an input endpoint.
+func InputURL(endpoint string, input string) string {
+	// Spaces are %20, not + as in HTML forms.
+	return InputEndpoint(endpoint) + "?" + strings.ReplaceAll(url.QueryEscape(input), "+", "%20")
+}
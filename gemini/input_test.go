@@ -0,0 +1,73 @@
+package gemini
+
+import (
+	"testing"
+
+	"gemini-grc/common/snapshot"
+	"github.com/guregu/null/v5"
+)
+
+func TestInputPrompt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url       string
+		code      int64
+		header    string
+		prompt    string
+		sensitive bool
+		ok        bool
+	}{
+		{"gemini://example.com/search", 10, "10 Enter search terms", "Enter search terms", false, true},
+		{"gemini://example.com/login", 11, "11 Password", "Password", true, true},
+		{"gemini://example.com/empty", 10, "10", "", false, true},
+		{"gemini://example.com/", 20, "20 text/gemini", "", false, false},
+		{"spartan://example.com/", 10, "10 Not gemini", "", false, false},
+	}
+	for _, tt := range tests {
+		s, err := snapshot.SnapshotFromURL(tt.url, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ResponseCode = null.IntFrom(tt.code)
+		s.Header = null.StringFrom(tt.header)
+		prompt, sensitive, ok := InputPrompt(s)
+		if prompt != tt.prompt || sensitive != tt.sensitive || ok != tt.ok {
+			t.Errorf("InputPrompt(%s) = %q, %v, %v, want %q, %v, %v", tt.header, prompt, sensitive, ok, tt.prompt, tt.sensitive, tt.ok)
+		}
+	}
+}
+
+func TestInputEndpoint(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"gemini://example.com/search", "gemini://example.com/search"},
+		{"gemini://example.com/search?smol%20net", "gemini://example.com/search"},
+		{"gemini://example.com/search#top", "gemini://example.com/search"},
+	}
+	for _, tt := range tests {
+		if got := InputEndpoint(tt.url); got != tt.want {
+			t.Errorf("InputEndpoint(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestInputURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		endpoint string
+		input    string
+		want     string
+	}{
+		{"gemini://example.com/search", "smol net", "gemini://example.com/search?smol%20net"},
+		{"gemini://example.com/search?old", "new", "gemini://example.com/search?new"},
+		{"gemini://example.com/search", "a&b=c?", "gemini://example.com/search?a%26b%3Dc%3F"},
+	}
+	for _, tt := range tests {
+		if got := InputURL(tt.endpoint, tt.input); got != tt.want {
+			t.Errorf("InputURL(%s, %s) = %s, want %s", tt.endpoint, tt.input, got, tt.want)
+		}
+	}
+}
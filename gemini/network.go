@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"gemini-grc/common/contextlog"
+	"gemini-grc/common/linkList"
 	"gemini-grc/common/snapshot"
 	_url "gemini-grc/common/url"
+	"gemini-grc/common/whiteList"
 	"gemini-grc/config"
 	"gemini-grc/contextutil"
 	"git.antanst.com/antanst/logging"
@@ -56,19 +58,20 @@ func Visit(ctx context.Context, url string) (s *snapshot.Snapshot, err error) {
 
 	s = UpdateSnapshotWithData(*s, data)
 
-	if !s.Error.Valid &&
-		s.MimeType.Valid &&
-		s.MimeType.String == "text/gemini" &&
-		len(s.GemText.ValueOrZero()) > 0 {
-		links := GetPageLinks(s.URL, s.GemText.String)
-		if len(links) > 0 {
-			s.Links = null.ValueFrom(links)
-		}
-	}
-
 	return s, nil
 }
 
+// Links returns the links of a successfully
+// fetched gemtext snapshot.
+func Links(s *snapshot.Snapshot) linkList.LinkList {
+	if s.Error.Valid ||
+		s.MimeType.ValueOrZero() != "text/gemini" ||
+		len(s.GemText.ValueOrZero()) == 0 {
+		return nil
+	}
+	return GetPageLinks(s.URL, s.GemText.String)
+}
+
 // ConnectAndGetData is a context-aware version of ConnectAndGetData
 // that returns the data from a GET request to a Gemini URL. It uses the context
 // for cancellation, timeout, and logging.
@@ -79,6 +82,7 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 	}
 
 	hostname := parsedURL.Hostname()
+	settings := config.CONFIG.ForHost(hostname)
 	port := parsedURL.Port()
 	if port == "" {
 		port = "1965"
@@ -90,7 +94,7 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 		return nil, err
 	}
 
-	timeoutDuration := time.Duration(config.CONFIG.ResponseTimeout) * time.Second
+	timeoutDuration := time.Duration(settings.ResponseTimeout) * time.Second
 
 	// Establish the underlying TCP connection with context-based cancellation
 	dialer := &net.Dialer{
@@ -102,6 +106,7 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Failed to establish TCP connection: %v", err)
 		return nil, xerrors.NewSimpleError(err)
 	}
+	connected := time.Now()
 
 	// Make sure we always close the connection
 	defer func() {
@@ -175,6 +180,7 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 	}
 
 	// Read response bytes in len(buf) byte chunks
+	headerRead := false
 	for {
 		// Check if the context has been canceled before each read
 		if err := ctx.Err(); err != nil {
@@ -185,8 +191,20 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 		if n > 0 {
 			data = append(data, buf[:n]...)
 		}
-		if len(data) > config.CONFIG.MaxResponseSize {
-			contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Response too large (max: %d bytes)", config.CONFIG.MaxResponseSize)
+		// The limits of the body depend on its MIME type
+		if headerEnds := slices.Index(data, '\n'); !headerRead && headerEnds != -1 {
+			headerRead = true
+			bodySettings := responseSettings(url, hostname, data[:headerEnds])
+			if bodySettings.ResponseTimeout != settings.ResponseTimeout {
+				deadlineErr := tlsConn.SetReadDeadline(connected.Add(time.Duration(bodySettings.ResponseTimeout) * time.Second))
+				if deadlineErr != nil {
+					return nil, xerrors.NewSimpleError(deadlineErr)
+				}
+			}
+			settings = bodySettings
+		}
+		if len(data) > settings.MaxResponseSize {
+			contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Response too large (max: %d bytes)", settings.MaxResponseSize)
 			return nil, xerrors.NewSimpleError(fmt.Errorf("response too large"))
 		}
 		if err != nil {
@@ -202,6 +220,13 @@ func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
 	return data, nil
 }
 
+// responseSettings returns the limits of a
+// response, given its header line.
+func responseSettings(url string, hostname string, header []byte) config.HostSettings {
+	_, mimeType, _ := getMimeTypeAndLang(strings.TrimSpace(string(header)))
+	return config.CONFIG.ForResponse(hostname, mimeType, whiteList.IsWhitelisted(url))
+}
+
 // UpdateSnapshotWithData processes the raw data from a Gemini response and populates the Snapshot.
 // This function is exported for use by the robotsMatch package.
 func UpdateSnapshotWithData(s snapshot.Snapshot, data []byte) *snapshot.Snapshot {
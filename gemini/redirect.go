@@ -0,0 +1,8 @@
+package gemini
+
+// Status codes of redirects. A permanent
+// redirect means the URL moved for good.
+const (
+	StatusTemporaryRedirect = 30
+	StatusPermanentRedirect = 31
+)
@@ -0,0 +1,172 @@
+package gopher
+
+import (
+	"strconv"
+	"strings"
+
+	"gemini-grc/common/linkList"
+	_url "gemini-grc/common/url"
+	"git.antanst.com/antanst/logging"
+)
+
+// MenuItem is a single line of a Gopher menu (gophermap).
+// See RFC 1436 section 3.8 for the line format:
+//
+//	<type><display string>TAB<selector>TAB<host>TAB<port>
+type MenuItem struct {
+	Type     byte
+	Display  string
+	Selector string
+	Host     string
+	Port     int
+}
+
+// Menu is a parsed Gopher menu.
+type Menu struct {
+	Items []MenuItem
+}
+
+// Item types that point to something other than
+// a Gopher document, and that we should never
+// try to fetch.
+var nonCrawlableItemTypes = map[byte]struct{}{
+	'2': {}, // CSO phone book server
+	'7': {}, // Index/search server, needs a query
+	'8': {}, // Telnet session
+	'T': {}, // TN3270 session
+}
+
+// IsInfo returns true for menu lines that
+// are not links, like informational messages
+// and errors.
+func (i MenuItem) IsInfo() bool {
+	return i.Type == 'i' || i.Type == '3'
+}
+
+// IsCrawlable returns true if the item is a link
+// to a resource we can fetch and store.
+func (i MenuItem) IsCrawlable() bool {
+	if i.IsInfo() || i.Host == "" {
+		return false
+	}
+	if _, ok := nonCrawlableItemTypes[i.Type]; ok {
+		return false
+	}
+	if i.Type == 'h' && strings.HasPrefix(i.Selector, "URL:") {
+		target := strings.TrimSpace(i.Selector[4:])
+		return target != "" && !strings.HasPrefix(strings.ToLower(target), "telnet:")
+	}
+	return true
+}
+
+// URL returns the URL this item points to.
+// Type `h` items with a `URL:` selector point
+// to external resources, everything else is
+// turned into a gopher:// URL that keeps the
+// item type as the first path segment. By
+// convention, text items on port 79 are
+// finger queries for the selector's user.
+func (i MenuItem) URL() string {
+	if i.Type == 'h' && strings.HasPrefix(i.Selector, "URL:") {
+		return strings.TrimSpace(i.Selector[4:])
+	}
+	if i.Type == '0' && i.Port == 79 {
+		return "finger://" + i.Host + "/" + strings.TrimPrefix(i.Selector, "/")
+	}
+
+	var url strings.Builder
+
+	// Protocol and host:port
+	url.WriteString("gopher://")
+	url.WriteString(i.Host)
+	url.WriteString(":")
+	url.WriteString(strconv.Itoa(i.Port))
+
+	// Path: always /type + selector
+	url.WriteString("/")
+	url.WriteByte(i.Type)
+	if strings.HasPrefix(i.Selector, "/") {
+		url.WriteString(i.Selector)
+	} else {
+		url.WriteString("/" + i.Selector)
+	}
+	return url.String()
+}
+
+// ParseMenu parses a Gopher menu. Parsing is lenient:
+// CRLF and LF line endings are accepted, parsing stops
+// at the terminating "." line, and malformed lines are
+// skipped instead of failing the whole menu.
+func ParseMenu(content string) Menu {
+	var menu Menu
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "." {
+			break
+		}
+		// Some servers indent lines, be lenient.
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			continue
+		}
+
+		item, ok := parseMenuLine(line)
+		if !ok {
+			continue
+		}
+		menu.Items = append(menu.Items, item)
+	}
+
+	return menu
+}
+
+func parseMenuLine(line string) (MenuItem, bool) {
+	item := MenuItem{Type: line[0]}
+
+	parts := strings.SplitN(line[1:], "\t", 5)
+	item.Display = strings.TrimSpace(parts[0])
+
+	// Informational lines often omit the other fields.
+	if item.IsInfo() {
+		return item, true
+	}
+
+	if len(parts) < 3 {
+		return MenuItem{}, false
+	}
+
+	item.Selector = strings.TrimSpace(parts[1])
+	item.Host = strings.TrimSpace(parts[2])
+	if item.Host == "" {
+		return MenuItem{}, false
+	}
+
+	item.Port = 70
+	if len(parts) > 3 {
+		port, err := strconv.Atoi(strings.TrimSpace(parts[3]))
+		if err == nil && port > 0 && port < 65536 {
+			item.Port = port
+		}
+	}
+
+	return item, true
+}
+
+// Links returns the crawlable links of the menu,
+// with the display strings as URL descriptions.
+func (m Menu) Links() linkList.LinkList {
+	var links linkList.LinkList
+	for _, item := range m.Items {
+		if !item.IsCrawlable() {
+			continue
+		}
+		link, err := _url.ParseURL(item.URL(), item.Display, true)
+		if err != nil {
+			logging.LogDebug("error parsing gopher menu link: %s", err)
+			continue
+		}
+		links = append(links, *link)
+	}
+	return links
+}
@@ -0,0 +1,115 @@
+package gopher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMenu(t *testing.T) {
+	t.Parallel()
+	input := "iWelcome to my hole\tfake\t(NULL)\t0\r\n" +
+		"1Phlog\t/phlog\texample.com\t70\r\n" +
+		"0About me\t/about.txt\texample.com\t7070\r\n" +
+		"hMy website\tURL:https://example.com/\texample.com\t70\r\n" +
+		"3Something went wrong\t\terror.host\t1\r\n" +
+		".\r\n" +
+		"1After the end\t/ignored\texample.com\t70\r\n"
+
+	menu := ParseMenu(input)
+
+	expected := []MenuItem{
+		{Type: 'i', Display: "Welcome to my hole"},
+		{Type: '1', Display: "Phlog", Selector: "/phlog", Host: "example.com", Port: 70},
+		{Type: '0', Display: "About me", Selector: "/about.txt", Host: "example.com", Port: 7070},
+		{Type: 'h', Display: "My website", Selector: "URL:https://example.com/", Host: "example.com", Port: 70},
+		{Type: '3', Display: "Something went wrong"},
+	}
+	assert.Equal(t, expected, menu.Items)
+}
+
+func TestParseMenuMalformedLines(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		want  []MenuItem
+	}{
+		{
+			name:  "Missing host",
+			input: "1No host\t/dir\t\t70",
+			want:  nil,
+		},
+		{
+			name:  "Missing fields",
+			input: "1Only a selector\t/dir",
+			want:  nil,
+		},
+		{
+			name:  "Missing port defaults to 70",
+			input: "1No port\t/dir\texample.com",
+			want:  []MenuItem{{Type: '1', Display: "No port", Selector: "/dir", Host: "example.com", Port: 70}},
+		},
+		{
+			name:  "Invalid port defaults to 70",
+			input: "1Bad port\t/dir\texample.com\tseventy",
+			want:  []MenuItem{{Type: '1', Display: "Bad port", Selector: "/dir", Host: "example.com", Port: 70}},
+		},
+		{
+			name:  "Gopher+ fields are ignored",
+			input: "1Gopher plus\t/dir\texample.com\t70\t+",
+			want:  []MenuItem{{Type: '1', Display: "Gopher plus", Selector: "/dir", Host: "example.com", Port: 70}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, ParseMenu(tt.input).Items)
+		})
+	}
+}
+
+func TestMenuItemIsCrawlable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		item MenuItem
+		want bool
+	}{
+		{MenuItem{Type: '0', Selector: "/a.txt", Host: "example.com", Port: 70}, true},
+		{MenuItem{Type: '1', Selector: "/dir", Host: "example.com", Port: 70}, true},
+		{MenuItem{Type: 'I', Selector: "/pic.png", Host: "example.com", Port: 70}, true},
+		{MenuItem{Type: 'i', Display: "info"}, false},
+		{MenuItem{Type: '3', Display: "error"}, false},
+		{MenuItem{Type: '2', Selector: "", Host: "cso.example.com", Port: 105}, false},
+		{MenuItem{Type: '7', Selector: "/search", Host: "example.com", Port: 70}, false},
+		{MenuItem{Type: '8', Selector: "", Host: "bbs.example.com", Port: 23}, false},
+		{MenuItem{Type: 'T', Selector: "", Host: "ibm.example.com", Port: 23}, false},
+		{MenuItem{Type: 'h', Selector: "URL:gemini://example.com/", Host: "example.com", Port: 70}, true},
+		{MenuItem{Type: 'h', Selector: "URL:telnet://bbs.example.com", Host: "example.com", Port: 70}, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.item.IsCrawlable(), "%c %s", tt.item.Type, tt.item.Selector)
+	}
+}
+
+func TestMenuLinks(t *testing.T) {
+	t.Parallel()
+	input := "iJust some text\t\t\t\n" +
+		"1Phlog\t/phlog\texample.com\t70\n" +
+		"8Telnet BBS\t\tbbs.example.com\t23\n" +
+		"hGemini mirror\tURL:gemini://example.com/\texample.com\t70\n" +
+		"0My .plan\talice\texample.com\t79\n" +
+		"hBob's plan\tURL:finger://bob@example.com\texample.com\t70\n"
+
+	links := ParseMenu(input).Links()
+
+	assert.Len(t, links, 4)
+	assert.Equal(t, "gopher://example.com:70/1/phlog", links[0].Full)
+	assert.Equal(t, "Phlog", links[0].Descr)
+	assert.Equal(t, "gemini://example.com:1965/", links[1].Full)
+	assert.Equal(t, "Gemini mirror", links[1].Descr)
+	assert.Equal(t, "finger://example.com:79/alice", links[2].Full)
+	assert.Equal(t, "finger://example.com:79/bob", links[3].Full)
+}
@@ -0,0 +1,71 @@
+package gopher
+
+import (
+	"mime"
+	"net/http"
+	"regexp"
+)
+
+// MimeTypeGophermap is the MIME type we store Gopher menus as.
+const MimeTypeGophermap = "text/x-gophermap"
+
+// MIME types of item types that are specific
+// enough to not need content sniffing.
+// Item types not in this map (9, I, s, d, 5...)
+// only tell us the broad category, so we
+// sniff the content for those.
+var itemTypeMimeTypes = map[byte]string{
+	'0': "text/plain",
+	'1': MimeTypeGophermap,
+	'4': "application/mac-binhex40",
+	'6': "text/x-uuencode",
+	'7': MimeTypeGophermap, // Search results are menus
+	'c': "text/calendar",
+	'g': "image/gif",
+	'h': "text/html",
+	'M': "message/rfc822",
+	'p': "application/postscript",
+	'P': "application/pdf",
+	':': "image/bmp",
+}
+
+var itemTypeRegex = regexp.MustCompile(`^/([\w])/.*`)
+
+// itemTypeFromPath returns the Gopher item type
+// encoded as the first path segment of our
+// gopher:// URLs, like /1/some/selector
+func itemTypeFromPath(urlPath string) (byte, bool) {
+	matches := itemTypeRegex.FindStringSubmatch(urlPath)
+	if len(matches) < 2 {
+		return 0, false
+	}
+	return matches[1][0], true
+}
+
+// detectMimeType returns the MIME type of a Gopher
+// response, based on the item type of the URL path.
+// If the item type is unknown or too generic,
+// the content is sniffed instead.
+func detectMimeType(urlPath string, data []byte) string {
+	// Selector-less URLs point to the root menu.
+	if urlPath == "" || urlPath == "/" {
+		return MimeTypeGophermap
+	}
+	if itemType, ok := itemTypeFromPath(urlPath); ok {
+		if mimeType, ok := itemTypeMimeTypes[itemType]; ok {
+			return mimeType
+		}
+	}
+	return sniffMimeType(data)
+}
+
+// sniffMimeType detects the MIME type from the
+// content, without any parameters like charset.
+func sniffMimeType(data []byte) string {
+	detected := http.DetectContentType(data)
+	mimeType, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
@@ -0,0 +1,99 @@
+package gopher
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	t.Parallel()
+	pngHeader := []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+	tests := []struct {
+		name    string
+		urlPath string
+		data    []byte
+		want    string
+	}{
+		{"Root menu", "/", []byte("1Dir\t/dir\texample.com\t70\r\n"), MimeTypeGophermap},
+		{"Empty path", "", []byte("1Dir\t/dir\texample.com\t70\r\n"), MimeTypeGophermap},
+		{"Menu", "/1/phlog", []byte("iHello\t\t\t\r\n"), MimeTypeGophermap},
+		{"Search results", "/7/search", []byte("0Result\t/r\texample.com\t70\r\n"), MimeTypeGophermap},
+		{"Text file", "/0/about.txt", []byte("Hello world"), "text/plain"},
+		{"HTML file", "/h/index.html", []byte("<html></html>"), "text/html"},
+		{"GIF image", "/g/cat.gif", []byte("GIF89a"), "image/gif"},
+		{"Image is sniffed", "/I/cat.png", pngHeader, "image/png"},
+		{"Binary is sniffed", "/9/archive.zip", []byte("PK\x03\x04"), "application/zip"},
+		{"Document is sniffed", "/d/paper.pdf", []byte("%PDF-1.4"), "application/pdf"},
+		{"Unknown binary", "/9/blob", []byte{0x00, 0x01, 0x02, 0xff}, "application/octet-stream"},
+		{"No item type", "/some/path", []byte("plain words"), "text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, detectMimeType(tt.urlPath, tt.data))
+		})
+	}
+}
+
+func TestVisitWithContextMimeTypes(t *testing.T) {
+	responses := map[string]string{
+		"/":          "iWelcome\t\t\t\r\n1Phlog\t/phlog\t127.0.0.1\t70\r\n0About\t/about.txt\t127.0.0.1\t70\r\n.\r\n",
+		"/about.txt": "Just a text file\r\n",
+		"/missing":   "3Not found\t\terror.host\t1\r\n",
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 1024)
+			n, _ := conn.Read(buf)
+			selector := string(buf[:n])
+			selector = selector[:len(selector)-2]
+			_, _ = conn.Write([]byte(responses[selector]))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	s, err := VisitWithContext(ctx, "gopher://"+address+"/")
+	assert.NoError(t, err)
+	assert.Equal(t, MimeTypeGophermap, s.MimeType.ValueOrZero())
+	assert.Equal(t, responses["/"], string(s.Data.ValueOrZero()))
+	assert.False(t, s.GemText.Valid)
+	assert.Len(t, Links(s), 2)
+
+	s, err = VisitWithContext(ctx, "gopher://"+address+"/0/about.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", s.MimeType.ValueOrZero())
+	assert.Equal(t, responses["/about.txt"], string(s.Data.ValueOrZero()))
+	assert.False(t, s.GemText.Valid)
+	assert.Empty(t, Links(s))
+
+	s, err = VisitWithContext(ctx, "gopher://"+address+"/0/missing")
+	assert.NoError(t, err)
+	assert.Contains(t, s.Error.ValueOrZero(), "gopher error")
+}
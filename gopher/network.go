@@ -6,11 +6,11 @@ import (
 	"io"
 	"net"
 	stdurl "net/url"
-	"regexp"
 	"strings"
 	"time"
 
 	commonErrors "gemini-grc/common/errors"
+	"gemini-grc/common/linkList"
 	"gemini-grc/config"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
@@ -64,12 +64,13 @@ func connectAndGetData(url string) ([]byte, error) {
 	}
 
 	hostname := parsedURL.Hostname()
+	settings := config.CONFIG.ForHost(hostname)
 	port := parsedURL.Port()
 	if port == "" {
 		port = "70"
 	}
-	host := fmt.Sprintf("%s:%s", hostname, port)
-	timeoutDuration := time.Duration(config.CONFIG.ResponseTimeout) * time.Second
+	host := net.JoinHostPort(hostname, port)
+	timeoutDuration := time.Duration(settings.ResponseTimeout) * time.Second
 	// Establish the underlying TCP connection.
 	dialer := &net.Dialer{
 		Timeout: timeoutDuration,
@@ -116,7 +117,7 @@ func connectAndGetData(url string) ([]byte, error) {
 			}
 			return nil, commonErrors.NewHostError(err)
 		}
-		if len(data) > config.CONFIG.MaxResponseSize {
+		if len(data) > settings.MaxResponseSize {
 			return nil, commonErrors.NewHostError(fmt.Errorf("response exceeded max"))
 		}
 	}
@@ -126,9 +127,8 @@ func connectAndGetData(url string) ([]byte, error) {
 
 func constructPayloadFromPath(urlpath string) string {
 	// remove Gopher item type in URL from payload, if one.
-	re := regexp.MustCompile(`^/[\w]/.*`)
 	payloadWithoutItemtype := urlpath
-	if re.Match([]byte(urlpath)) {
+	if itemTypeRegex.MatchString(urlpath) {
 		payloadWithoutItemtype = strings.Join(strings.Split(urlpath, "/")[2:], "/")
 	}
 	if !strings.HasPrefix(payloadWithoutItemtype, "/") {
@@ -137,6 +137,12 @@ func constructPayloadFromPath(urlpath string) string {
 	return payloadWithoutItemtype
 }
 
+// SelectorFromPath returns the Gopher selector
+// of a gopher:// URL path, without the item type.
+func SelectorFromPath(urlPath string) string {
+	return constructPayloadFromPath(urlPath)
+}
+
 func checkForError(utfData string) error {
 	lines := strings.Split(strings.TrimSpace(utfData), "\n")
 	var firstLine string
@@ -152,69 +158,16 @@ func checkForError(utfData string) error {
 	return nil
 }
 
-func getGopherPageLinks(content string) []string {
-	var links []string
-
-	lines := strings.Split(strings.TrimSpace(content), "\n")
-
-	for _, line := range lines {
-		if line == "" || line == "." {
-			continue
-		}
-
-		if len(line) < 1 {
-			continue
-		}
-
-		itemType := line[0]
-		if itemType == 'i' {
-			continue
-		}
-
-		parts := strings.SplitN(line[1:], "\t", 4)
-		if len(parts) < 3 {
-			continue
-		}
-
-		selector := strings.TrimSpace(parts[1])
-		host := strings.TrimSpace(parts[2])
-
-		if host == "" {
-			continue
-		}
-
-		// Handle HTML links first
-		if itemType == 'h' && strings.HasPrefix(selector, "URL:") {
-			if url := strings.TrimSpace(selector[4:]); url != "" {
-				links = append(links, url)
-			}
-			continue
-		}
-
-		// For gopher links, build URL carefully
-		var url strings.Builder
-
-		// Protocol and host:port
-		url.WriteString("gopher://")
-		url.WriteString(host)
-		url.WriteString(":")
-		if len(parts) > 3 && strings.TrimSpace(parts[3]) != "" {
-			url.WriteString(strings.TrimSpace(parts[3]))
-		} else {
-			url.WriteString("70")
-		}
-
-		// Path: always /type + selector
-		url.WriteString("/")
-		url.WriteString(string(itemType))
-		if strings.HasPrefix(selector, "/") {
-			url.WriteString(selector)
-		} else {
-			url.WriteString("/" + selector)
-		}
-
-		links = append(links, url.String())
-	}
+// looksLikeMenuLine returns true if the first
+// line of the response has the tab separated
+// fields of a menu line.
+func looksLikeMenuLine(data []byte) bool {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	return strings.Count(firstLine, "\t") >= 2
+}
 
-	return links
+// getGopherPageLinks parses a Gopher menu
+// and returns its crawlable links.
+func getGopherPageLinks(content string) linkList.LinkList {
+	return ParseMenu(content).Links()
 }
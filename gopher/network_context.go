@@ -7,15 +7,14 @@ import (
 	"io"
 	"net"
 	stdurl "net/url"
+	"strings"
 	"time"
-	"unicode/utf8"
 
 	"gemini-grc/common/contextlog"
 	commonErrors "gemini-grc/common/errors"
 	"gemini-grc/common/linkList"
 	"gemini-grc/common/snapshot"
-	"gemini-grc/common/text"
-	_url "gemini-grc/common/url"
+	"gemini-grc/common/whiteList"
 	"gemini-grc/config"
 	"gemini-grc/contextutil"
 	"git.antanst.com/antanst/logging"
@@ -46,7 +45,7 @@ func VisitWithContext(ctx context.Context, url string) (*snapshot.Snapshot, erro
 		return nil, err
 	}
 
-	data, err := connectAndGetDataWithContext(gopherCtx, url)
+	data, err := ConnectAndGetDataWithContext(gopherCtx, url)
 	if err != nil {
 		contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Error: %s", err.Error())
 		if IsGopherError(err) || commonErrors.IsHostError(err) {
@@ -61,67 +60,66 @@ func VisitWithContext(ctx context.Context, url string) (*snapshot.Snapshot, erro
 		return nil, err
 	}
 
-	isValidUTF8 := utf8.ValidString(string(data))
-	if isValidUTF8 {
-		s.GemText = null.StringFrom(text.RemoveNullChars(string(data)))
-		contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Response is valid UTF-8 text (%d bytes)", len(data))
-	} else {
-		s.Data = null.ValueFrom(data)
-		contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Response is binary data (%d bytes)", len(data))
-	}
-
-	if !isValidUTF8 {
-		return s, nil
-	}
-
-	responseError := checkForError(string(data))
-	if responseError != nil {
-		contextlog.LogErrorWithContext(gopherCtx, logging.GetSlogger(), "Gopher server returned error: %v", responseError)
-		s.Error = null.StringFrom(responseError.Error())
-		return s, nil
-	}
-
-	// Extract links from the response
-	links := getGopherPageLinks(string(data))
-	linkURLs := linkList.LinkList(make([]_url.URL, len(links)))
-
-	for i, link := range links {
-		linkURL, err := _url.ParseURL(link, "", true)
-		if err == nil {
-			linkURLs[i] = *linkURL
+	// Everything is stored as raw data, the `GemText`
+	// field is only for text/gemini content.
+	mimeType := detectMimeType(s.URL.Path, data)
+	s.MimeType = null.StringFrom(mimeType)
+	s.Data = null.ValueFrom(data)
+	contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Response is %s (%d bytes)", mimeType, len(data))
+
+	// Servers reply with an error menu regardless of
+	// the requested item type, so check text files too.
+	isMenu := mimeType == MimeTypeGophermap
+	if isMenu || (strings.HasPrefix(mimeType, "text/") && looksLikeMenuLine(data)) {
+		responseError := checkForError(string(data))
+		if responseError != nil {
+			contextlog.LogErrorWithContext(gopherCtx, logging.GetSlogger(), "Gopher server returned error: %v", responseError)
+			s.Error = null.StringFrom(responseError.Error())
+			return s, nil
 		}
 	}
 
-	if len(links) != 0 {
-		s.Links = null.ValueFrom(linkURLs)
-		contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Found %d links in gopher page", len(links))
-	}
-
 	contextlog.LogDebugWithContext(gopherCtx, logging.GetSlogger(), "Successfully visited Gopher URL: %s", url)
 	return s, nil
 }
 
-// connectAndGetDataWithContext is a context-aware version of connectAndGetData
-func connectAndGetDataWithContext(ctx context.Context, url string) ([]byte, error) {
+// Links returns the crawlable links of a menu
+// snapshot. Search servers are only crawled with
+// the configured sample queries.
+func Links(s *snapshot.Snapshot) linkList.LinkList {
+	if s.Error.Valid || s.MimeType.ValueOrZero() != MimeTypeGophermap {
+		return nil
+	}
+	menu := ParseMenu(string(s.Data.ValueOrZero()))
+	links := menu.Links()
+	if len(config.CONFIG.GopherSearchQueries) > 0 {
+		links = append(links, menu.SearchLinks(config.CONFIG.GopherSearchQueries)...)
+	}
+	return links
+}
+
+// ConnectAndGetDataWithContext is a context-aware version of connectAndGetData
+func ConnectAndGetDataWithContext(ctx context.Context, url string) ([]byte, error) {
 	parsedURL, err := stdurl.Parse(url)
 	if err != nil {
 		return nil, xerrors.NewError(fmt.Errorf("error parsing URL: %w", err), 0, "", false)
 	}
 
 	hostname := parsedURL.Hostname()
+	settings := config.CONFIG.ForHost(hostname)
 	port := parsedURL.Port()
 	if port == "" {
 		port = "70"
 	}
-	host := fmt.Sprintf("%s:%s", hostname, port)
+	host := net.JoinHostPort(hostname, port)
 
 	// Use the context's deadline if it has one, otherwise use the config timeout
 	var timeoutDuration time.Duration
-	deadline, ok := ctx.Deadline()
-	if ok {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
 		timeoutDuration = time.Until(deadline)
 	} else {
-		timeoutDuration = time.Duration(config.CONFIG.ResponseTimeout) * time.Second
+		timeoutDuration = time.Duration(settings.ResponseTimeout) * time.Second
 	}
 
 	// Check if the context is canceled
@@ -142,6 +140,7 @@ func connectAndGetDataWithContext(ctx context.Context, url string) ([]byte, erro
 		contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Failed to connect: %v", err)
 		return nil, commonErrors.NewHostError(err)
 	}
+	connected := time.Now()
 
 	// Make sure we always close the connection
 	defer func() {
@@ -169,6 +168,9 @@ func connectAndGetDataWithContext(ctx context.Context, url string) ([]byte, erro
 
 	// Send Gopher request to trigger server response
 	payload := constructPayloadFromPath(parsedURL.Path)
+	if query, ok := searchQueryFromURL(parsedURL); ok {
+		payload += "\t" + query
+	}
 	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Sending request with payload: %s", payload)
 	_, err = conn.Write([]byte(fmt.Sprintf("%s\r\n", payload)))
 	if err != nil {
@@ -185,6 +187,20 @@ func connectAndGetDataWithContext(ctx context.Context, url string) ([]byte, erro
 
 		n, err := conn.Read(buf)
 		if n > 0 {
+			// The limits of the rest depend on the MIME type,
+			// which is sniffed from the first chunk if the
+			// item type isn't specific enough.
+			if len(data) == 0 {
+				mimeType := detectMimeType(parsedURL.Path, buf[:n])
+				responseSettings := config.CONFIG.ForResponse(hostname, mimeType, whiteList.IsWhitelisted(url))
+				if responseSettings.ResponseTimeout != settings.ResponseTimeout && !hasDeadline {
+					deadlineErr := conn.SetReadDeadline(connected.Add(time.Duration(responseSettings.ResponseTimeout) * time.Second))
+					if deadlineErr != nil {
+						return nil, commonErrors.NewHostError(deadlineErr)
+					}
+				}
+				settings = responseSettings
+			}
 			data = append(data, buf[:n]...)
 		}
 		if err != nil {
@@ -194,8 +210,8 @@ func connectAndGetDataWithContext(ctx context.Context, url string) ([]byte, erro
 			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Error reading data: %v", err)
 			return nil, commonErrors.NewHostError(err)
 		}
-		if len(data) > config.CONFIG.MaxResponseSize {
-			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Response too large (max: %d bytes)", config.CONFIG.MaxResponseSize)
+		if len(data) > settings.MaxResponseSize {
+			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Response too large (max: %d bytes)", settings.MaxResponseSize)
 			return nil, commonErrors.NewHostError(fmt.Errorf("response exceeded max"))
 		}
 	}
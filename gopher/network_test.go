@@ -1,7 +1,9 @@
 package gopher
 
 import (
+	"context"
 	"net"
+	"strings"
 	"testing"
 
 	"gemini-grc/common/errors"
@@ -296,3 +298,40 @@ func TestConnectAndGetDataTimeout(t *testing.T) {
 		t.Logf("Successfully timed out: %v", err)
 	}
 }
+
+func TestConnectAndGetDataMimePolicy(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	// Reply to every request with 2000 bytes
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Read(make([]byte, 1024))
+			_, _ = conn.Write([]byte("GIF89a" + strings.Repeat("x", 1994)))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+	config.CONFIG.Policies = []config.Policy{{MIME: "image/*", MaxResponseSize: 4096}}
+
+	address := listener.Addr().String()
+	data, err := ConnectAndGetDataWithContext(context.Background(), "gopher://"+address+"/g/image.gif")
+	assert.NoError(t, err)
+	assert.Len(t, data, 2000)
+
+	_, err = ConnectAndGetDataWithContext(context.Background(), "gopher://"+address+"/0/text.txt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package gopher
+
+import (
+	stdurl "net/url"
+
+	"gemini-grc/common/linkList"
+	_url "gemini-grc/common/url"
+	"git.antanst.com/antanst/logging"
+)
+
+// Type 7 items are search servers: the client
+// sends the selector, a TAB and the query, and
+// gets back a menu of results. We represent a
+// search as the item's URL, with the query as
+// the URL query string, like Gopher clients do:
+//
+//	gopher://example.com:70/7/search?gopher+history
+
+// SearchURL returns the URL of running
+// a query against a type 7 item.
+func (i MenuItem) SearchURL(query string) string {
+	return i.URL() + "?" + stdurl.QueryEscape(query)
+}
+
+// SearchLinks returns the URLs of running each
+// query against every search item of the menu.
+func (m Menu) SearchLinks(queries []string) linkList.LinkList {
+	var links linkList.LinkList
+	for _, item := range m.Items {
+		if item.Type != '7' || item.Host == "" {
+			continue
+		}
+		for _, query := range queries {
+			link, err := _url.ParseURL(item.SearchURL(query), item.Display, true)
+			if err != nil {
+				logging.LogDebug("error parsing gopher search link: %s", err)
+				continue
+			}
+			links = append(links, *link)
+		}
+	}
+	return links
+}
+
+// SearchQuery returns the query of a
+// search URL, and if it is one at all.
+func SearchQuery(u *_url.URL) (string, bool) {
+	parsed, err := stdurl.Parse(u.Full)
+	if err != nil || parsed.Scheme != "gopher" {
+		return "", false
+	}
+	return searchQueryFromURL(parsed)
+}
+
+func searchQueryFromURL(u *stdurl.URL) (string, bool) {
+	if itemType, ok := itemTypeFromPath(u.Path); !ok || itemType != '7' {
+		return "", false
+	}
+	if u.RawQuery == "" {
+		return "", false
+	}
+	query, err := stdurl.QueryUnescape(u.RawQuery)
+	if err != nil {
+		return "", false
+	}
+	return query, true
+}
@@ -0,0 +1,111 @@
+package gopher
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	_url "gemini-grc/common/url"
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMenuSearchLinks(t *testing.T) {
+	t.Parallel()
+	input := "1Phlog\t/phlog\texample.com\t70\r\n" +
+		"7Search Gopherspace\t/v2/vs\tgopher.floodgap.com\t70\r\n" +
+		"7Broken search\t/search\t\t70\r\n"
+
+	menu := ParseMenu(input)
+	links := menu.SearchLinks([]string{"gopher", "smol net"})
+
+	assert.Len(t, links, 2)
+	assert.Equal(t, "gopher://gopher.floodgap.com:70/7/v2/vs?gopher", links[0].Full)
+	assert.Equal(t, "gopher://gopher.floodgap.com:70/7/v2/vs?smol+net", links[1].Full)
+	assert.Equal(t, "Search Gopherspace", links[0].Descr)
+
+	// Search items are never crawled without a query
+	assert.Len(t, menu.Links(), 1)
+}
+
+func TestSearchQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url   string
+		query string
+		ok    bool
+	}{
+		{"gopher://example.com/7/search?smol+net", "smol net", true},
+		{"gopher://example.com/7/search?caf%C3%A9", "café", true},
+		{"gopher://example.com/7/search", "", false},
+		{"gopher://example.com/1/menu?query", "", false},
+		{"gemini://example.com/7/search?query", "", false},
+	}
+	for _, tt := range tests {
+		u, err := _url.ParseURL(tt.url, "", true)
+		assert.NoError(t, err)
+		query, ok := SearchQuery(u)
+		assert.Equal(t, tt.ok, ok, tt.url)
+		assert.Equal(t, tt.query, query, tt.url)
+	}
+}
+
+func TestVisitWithContextSearch(t *testing.T) {
+	responses := map[string]string{
+		"/":                    "7Search\t/search\t127.0.0.1\t70\r\n.\r\n",
+		"/search\tgopher hole": "0Result\t/result.txt\t127.0.0.1\t70\r\n.\r\n",
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 1024)
+			n, _ := conn.Read(buf)
+			request := string(buf[:n])
+			request = request[:len(request)-2]
+			_, _ = conn.Write([]byte(responses[request]))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	// No queries configured, search servers are skipped
+	config.CONFIG.GopherSearchQueries = nil
+	s, err := VisitWithContext(ctx, "gopher://"+address+"/")
+	assert.NoError(t, err)
+	assert.Empty(t, Links(s))
+
+	config.CONFIG.GopherSearchQueries = []string{"gopher hole"}
+	s, err = VisitWithContext(ctx, "gopher://"+address+"/")
+	assert.NoError(t, err)
+	links := Links(s)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "gopher://127.0.0.1:70/7/search?gopher+hole", links[0].Full)
+	}
+
+	// The results of a search are a menu
+	s, err = VisitWithContext(ctx, "gopher://"+address+"/7/search?gopher+hole")
+	assert.NoError(t, err)
+	assert.False(t, s.Error.Valid)
+	assert.Equal(t, MimeTypeGophermap, s.MimeType.ValueOrZero())
+	assert.Len(t, Links(s), 1)
+}
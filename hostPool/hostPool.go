@@ -7,16 +7,22 @@ import (
 	"time"
 
 	"gemini-grc/common/contextlog"
+	"gemini-grc/config"
 	"gemini-grc/contextutil"
+	"gemini-grc/metrics"
 	"git.antanst.com/antanst/logging"
 	"git.antanst.com/antanst/xerrors"
 )
 
-var hostPool = HostPool{hostnames: make(map[string]struct{})}
+var hostPool = HostPool{hostnames: make(map[string]struct{}), nextVisits: make(map[string]time.Time)}
 
 type HostPool struct {
 	hostnames map[string]struct{}
-	lock      sync.RWMutex
+	// When hosts with a request interval can
+	// be added again. Entries are deleted once
+	// that time has passed.
+	nextVisits map[string]time.Time
+	lock       sync.RWMutex
 }
 
 // RemoveHostFromPool removes a host from the pool with context awareness
@@ -24,6 +30,9 @@ func RemoveHostFromPool(ctx context.Context, key string) {
 	hostCtx := contextutil.ContextWithComponent(ctx, "hostPool")
 	hostPool.lock.Lock()
 	delete(hostPool.hostnames, key)
+	if interval := config.CONFIG.ForHost(key).RequestInterval; interval > 0 {
+		hostPool.nextVisits[key] = time.Now().Add(interval)
+	}
 	hostPool.lock.Unlock()
 
 	// Add some jitter
@@ -32,7 +41,9 @@ func RemoveHostFromPool(ctx context.Context, key string) {
 }
 
 // AddHostToHostPool adds a host to the host pool with context awareness.
-// Blocks until the host is added or the context is canceled.
+// Blocks until the host is added or the context is canceled. Hosts
+// with a request interval are added only once it has passed since
+// they were last removed.
 func AddHostToHostPool(ctx context.Context, key string) error {
 	// Create a hostPool-specific context
 	hostCtx := contextutil.ContextWithComponent(ctx, "hostPool")
@@ -41,6 +52,13 @@ func AddHostToHostPool(ctx context.Context, key string) error {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
+	waiting := false
+	defer func() {
+		if waiting {
+			metrics.HostsWaiting.Dec()
+		}
+	}()
+
 	// We continuously poll the pool,
 	// and if the host isn't already
 	// there, we add it.
@@ -54,14 +72,20 @@ func AddHostToHostPool(ctx context.Context, key string) error {
 		}
 
 		hostPool.lock.Lock()
+		pruneNextVisits()
 		_, exists := hostPool.hostnames[key]
-		if !exists {
+		_, tooSoon := hostPool.nextVisits[key]
+		if !exists && !tooSoon {
 			hostPool.hostnames[key] = struct{}{}
 			hostPool.lock.Unlock()
 			contextlog.LogDebugWithContext(hostCtx, logging.GetSlogger(), "Added host %s to pool", key)
 			return nil
 		}
 		hostPool.lock.Unlock()
+		if !waiting {
+			waiting = true
+			metrics.HostsWaiting.Inc()
+		}
 
 		// Wait for next tick or context cancellation
 		select {
@@ -73,3 +97,14 @@ func AddHostToHostPool(ctx context.Context, key string) error {
 		}
 	}
 }
+
+// pruneNextVisits deletes the hosts whose request
+// interval has passed. Must hold hostPool.lock.
+func pruneNextVisits() {
+	now := time.Now()
+	for key, next := range hostPool.nextVisits {
+		if !now.Before(next) {
+			delete(hostPool.nextVisits, key)
+		}
+	}
+}
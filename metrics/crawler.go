@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics of the crawler.
+var (
+	Requests = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_requests_total",
+		Help: "Requests by protocol and response status code, or \"none\" when there was no response.",
+	}, []string{"protocol", "code"})
+	Errors = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_errors_total",
+		Help: "Errors by class: host (no response), a protocol name (error response), robots or blacklist.",
+	}, []string{"class"})
+	BytesFetched = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_fetched_bytes_total",
+		Help: "Bytes of response bodies fetched, by protocol.",
+	}, []string{"protocol"})
+	FetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{ //nolint:gochecknoglobals
+		Name:    "gemini_grc_fetch_duration_seconds",
+		Help:    "Time to fetch a URL, by protocol.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"protocol"})
+	QueueSize = promauto.NewGauge(prometheus.GaugeOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_queue_urls",
+		Help: "URLs waiting in the queue.",
+	})
+	HostsWaiting = promauto.NewGauge(prometheus.GaugeOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_host_pool_waiting",
+		Help: "Workers waiting for a host another worker is visiting.",
+	})
+	TxDuration = promauto.NewHistogram(prometheus.HistogramOpts{ //nolint:gochecknoglobals
+		Name:    "gemini_grc_db_transaction_duration_seconds",
+		Help:    "Duration of worker database transactions.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+	Snapshots = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_snapshots_total",
+		Help: "Visited URLs by outcome: saved, identical or near_duplicate.",
+	}, []string{"result"})
+	StoredBytes = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_stored_bytes_total",
+		Help: "Bytes of content stored in saved snapshots, by MIME type.",
+	}, []string{"mimetype"})
+	SkippedContent = promauto.NewCounterVec(prometheus.CounterOpts{ //nolint:gochecknoglobals
+		Name: "gemini_grc_skipped_content_total",
+		Help: "Saved snapshots whose content wasn't stored, by MIME type.",
+	}, []string{"mimetype"})
+)
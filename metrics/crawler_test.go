@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestHandlerServesCrawlerMetrics(t *testing.T) {
+	Requests.WithLabelValues("gemini", "20").Inc()
+	FetchDuration.WithLabelValues("gemini").Observe(0.2)
+
+	rec := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`gemini_grc_requests_total{code="20",protocol="gemini"} 1`,
+		`gemini_grc_fetch_duration_seconds_bucket{protocol="gemini",le="0.25"} 1`,
+		"gemini_grc_queue_urls 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics don't contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"gemini-grc/config"
+	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var server *http.Server //nolint:gochecknoglobals
+
+// Initialize serves the metrics on
+// /metrics, if an address is configured.
+func Initialize() error {
+	if config.CONFIG.MetricsAddr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", config.CONFIG.MetricsAddr)
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("could not listen for metrics on %s: %w", config.CONFIG.MetricsAddr, err), 0, "", true)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.LogError("Metrics server failed: %v", err)
+		}
+	}()
+	logging.LogInfo("Serving metrics on http://%s/metrics", listener.Addr())
+	return nil
+}
+
+func Shutdown() error {
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	server = nil
+	if err != nil {
+		return xerrors.NewError(fmt.Errorf("could not stop metrics server: %w", err), 0, "", false)
+	}
+	return nil
+}
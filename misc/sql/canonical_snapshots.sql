@@ -0,0 +1,15 @@
+-- File: canonical_snapshots.sql
+-- Latest snapshot of each URL, with the URL it should be
+-- shown under. URLs that moved permanently show their
+-- canonical URL.
+-- Usage: \i misc/sql/canonical_snapshots.sql
+
+SELECT DISTINCT ON (s.url)
+    s.url,
+    COALESCE(c.canonical_url, s.url) AS canonical_url,
+    s.response_code,
+    s.timestamp
+FROM snapshots s
+LEFT JOIN canonical_urls c ON c.url = s.url
+WHERE s.removed_at IS NULL
+ORDER BY s.url, s.timestamp DESC;
@@ -1,18 +1,30 @@
 DROP TABLE IF EXISTS snapshots;
 DROP TABLE IF EXISTS urls;
+DROP TABLE IF EXISTS robots;
+DROP TABLE IF EXISTS robots_history;
+DROP TABLE IF EXISTS gopher_searches;
+DROP TABLE IF EXISTS input_endpoints;
+DROP TABLE IF EXISTS redirects;
+DROP TABLE IF EXISTS canonical_urls;
+DROP TABLE IF EXISTS quarantined_urls;
+DROP TABLE IF EXISTS removal_log;
+DROP TABLE IF EXISTS takedown_rules;
 
 CREATE TABLE urls (
     id SERIAL PRIMARY KEY,
     url TEXT NOT NULL,
     host TEXT NOT NULL,
     timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    being_processed BOOLEAN
+    being_processed BOOLEAN,
+    depth INTEGER NOT NULL DEFAULT 0, -- Link depth from a seed or capsule root
+    priority INTEGER NOT NULL DEFAULT 0 -- Higher is crawled first
 );
 
 CREATE UNIQUE INDEX urls_url_key ON urls (url);
 CREATE INDEX idx_urls_url ON urls (url);
 CREATE INDEX idx_urls_timestamp ON urls (timestamp);
 CREATE INDEX idx_being_processed ON urls (being_processed);
+CREATE INDEX idx_urls_host ON urls (host);
 
 CREATE TABLE snapshots (
     id SERIAL PRIMARY KEY,
@@ -27,10 +39,14 @@ CREATE TABLE snapshots (
     response_code INTEGER,
     error TEXT,
     header TEXT,
-    last_crawled TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    last_crawled TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    simhash BIGINT, -- SimHash of text content
+    removed_at TIMESTAMP WITH TIME ZONE, -- Set when hidden from the archive
+    skipped_content BOOLEAN NOT NULL DEFAULT false -- Content not stored because of its MIME type
 );
 
 CREATE UNIQUE INDEX idx_url_timestamp ON snapshots (url, timestamp);
+CREATE INDEX idx_snapshots_simhash ON snapshots (simhash);
 CREATE INDEX idx_url ON snapshots (url);
 CREATE INDEX idx_timestamp ON snapshots (timestamp);
 CREATE INDEX idx_mimetype ON snapshots (mimetype);
@@ -43,4 +59,114 @@ CREATE INDEX idx_response_code_error_nulls ON snapshots (response_code, error) W
 CREATE INDEX idx_snapshots_unprocessed ON snapshots (host) WHERE response_code IS NULL AND error IS NULL;
 CREATE INDEX idx_url_latest ON snapshots (url, timestamp DESC);
 CREATE INDEX idx_last_crawled ON snapshots (last_crawled);
-CREATE INDEX idx_url_last_crawled ON snapshots (url, last_crawled DESC);
\ No newline at end of file
+CREATE INDEX idx_url_last_crawled ON snapshots (url, last_crawled DESC);
+
+-- Cached robots.txt per scheme://host:port
+CREATE TABLE robots (
+    host_key TEXT PRIMARY KEY,
+    body TEXT,
+    rules JSONB,
+    status TEXT NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_robots_expires_at ON robots (expires_at);
+
+-- Every robots.txt rules change, for auditing
+CREATE TABLE robots_history (
+    id SERIAL PRIMARY KEY,
+    host_key TEXT NOT NULL,
+    body TEXT,
+    rules JSONB,
+    status TEXT NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_robots_history_host_key ON robots_history (host_key, changed_at DESC);
+
+-- Gopher searches (type 7) we ran, with the
+-- menu that linked to the search server.
+CREATE TABLE gopher_searches (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    menu_url TEXT NOT NULL,
+    query TEXT NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_gopher_searches_host ON gopher_searches (host);
+CREATE INDEX idx_gopher_searches_menu_url ON gopher_searches (menu_url);
+
+-- Gemini URLs that ask for input (status 10/11)
+CREATE TABLE input_endpoints (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_input_endpoints_host ON input_endpoints (host);
+
+-- Redirects we saw, as edges between URLs
+CREATE TABLE redirects (
+    id SERIAL PRIMARY KEY,
+    from_url TEXT NOT NULL,
+    to_url TEXT NOT NULL,
+    code INTEGER NOT NULL,
+    seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX redirects_edge_key ON redirects (from_url, to_url, code);
+CREATE INDEX idx_redirects_to_url ON redirects (to_url);
+
+-- URLs that moved permanently, and where they live now.
+-- These are not recrawled.
+CREATE TABLE canonical_urls (
+    url TEXT PRIMARY KEY,
+    canonical_url TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_canonical_urls_canonical_url ON canonical_urls (canonical_url);
+
+-- Suspected spider trap URLs, kept
+-- out of the queue until reviewed
+CREATE TABLE quarantined_urls (
+    url TEXT PRIMARY KEY,
+    host TEXT NOT NULL,
+    source_url TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 1,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_quarantined_urls_host ON quarantined_urls (host);
+
+-- Who removed which URLs from the queue or the archive, and why
+CREATE TABLE removal_log (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    target TEXT NOT NULL, -- queue or snapshots
+    action TEXT NOT NULL, -- delete or mark
+    snapshots INTEGER NOT NULL DEFAULT 0, -- Snapshots affected
+    removed_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    removed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_removal_log_url ON removal_log (url);
+
+-- Content authors asked us not to crawl or archive
+CREATE TABLE takedown_rules (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL, -- host, prefix or regex
+    pattern TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
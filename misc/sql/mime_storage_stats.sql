@@ -0,0 +1,17 @@
+-- File: mime_storage_stats.sql
+-- Shows how much storage each MIME type takes, and how many
+-- snapshots of it were saved without content (see -store-mime-types
+-- and -skip-mime-types)
+-- Usage: \i misc/sql/mime_storage_stats.sql
+
+SELECT
+    COALESCE(mimetype, '(none)') AS mimetype,
+    COUNT(*) AS snapshots,
+    COUNT(*) FILTER (WHERE skipped_content) AS skipped_content,
+    pg_size_pretty(SUM(COALESCE(octet_length(data), 0) + COALESCE(octet_length(gemtext), 0))) AS content_size,
+    pg_size_pretty(AVG(COALESCE(octet_length(data), 0) + COALESCE(octet_length(gemtext), 0))::BIGINT) AS avg_content_size,
+    ROUND(SUM(COALESCE(octet_length(data), 0) + COALESCE(octet_length(gemtext), 0)) * 100.0
+        / NULLIF(SUM(SUM(COALESCE(octet_length(data), 0) + COALESCE(octet_length(gemtext), 0))) OVER (), 0), 2) AS percentage
+FROM snapshots
+GROUP BY mimetype
+ORDER BY SUM(COALESCE(octet_length(data), 0) + COALESCE(octet_length(gemtext), 0)) DESC;
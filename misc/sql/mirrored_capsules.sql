@@ -0,0 +1,25 @@
+-- File: mirrored_capsules.sql
+-- Pages on different hosts with near-identical content, by the SimHash
+-- of their latest snapshot. Pairs of hosts sharing many pages are likely
+-- mirrors. Compares every pair of fingerprints, so it's slow on large
+-- archives; lower the distance to 0 to only use the simhash index.
+-- Usage: \i misc/sql/mirrored_capsules.sql
+
+WITH latest AS (
+    SELECT DISTINCT ON (url) url, host, simhash
+    FROM snapshots
+    WHERE simhash IS NOT NULL
+    ORDER BY url, timestamp DESC
+)
+SELECT
+    a.host AS host,
+    b.host AS mirror_host,
+    COUNT(*) AS shared_pages,
+    MIN(a.url) AS example_url,
+    MIN(b.url) AS example_mirror_url
+FROM latest a
+JOIN latest b ON a.host < b.host
+    AND bit_count((a.simhash # b.simhash)::bit(64)) <= 3
+GROUP BY a.host, b.host
+HAVING COUNT(*) >= 3
+ORDER BY shared_pages DESC;
@@ -0,0 +1,14 @@
+-- File: quarantined_hosts.sql
+-- Suspected spider traps per host and reason, with an example URL
+-- Usage: \i misc/sql/quarantined_hosts.sql
+
+SELECT
+    host,
+    regexp_replace(reason, '[0-9]+', 'N', 'g') AS reason,
+    COUNT(*) AS url_count,
+    SUM(hits) AS total_hits,
+    MIN(url) AS example_url,
+    MAX(last_seen) AS last_seen
+FROM quarantined_urls
+GROUP BY host, regexp_replace(reason, '[0-9]+', 'N', 'g')
+ORDER BY url_count DESC;
@@ -0,0 +1,171 @@
+package nex
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	stdurl "net/url"
+	"path"
+	"strings"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/plaintext"
+	"gemini-grc/common/snapshot"
+	"gemini-grc/contextutil"
+	"gemini-grc/gemini"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/guregu/null/v5"
+)
+
+// References:
+// nex://nightfall.city/nex/info/specification.txt
+
+// Nex is a plaintext protocol on port 1900.
+// The request is just the path followed by CRLF,
+// and the response is the document itself, with
+// no header. Paths ending in `/` are directory
+// listings, where `=> URL description` lines are
+// links. Anything else is a file whose type is
+// given by its extension, text if there is none.
+
+// MimeTypeDirectory is the MIME type
+// we store Nex directory listings as.
+const MimeTypeDirectory = "text/x-nex-directory"
+
+// Common smolnet extensions that the
+// standard library doesn't know about.
+var extensionMimeTypes = map[string]string{ //nolint:gochecknoglobals
+	".gmi":    "text/gemini",
+	".gemini": "text/gemini",
+	".txt":    "text/plain",
+	".md":     "text/markdown",
+}
+
+// Visit visits a given URL using the Nex protocol,
+// and returns a populated snapshot. Network errors
+// are stored in the snapshot; an error is returned
+// only when a snapshot could not be constructed.
+func Visit(ctx context.Context, url string) (s *snapshot.Snapshot, err error) {
+	nexCtx := contextutil.ContextWithComponent(ctx, "nex")
+
+	s, err = snapshot.SnapshotFromURL(url, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	data, err := ConnectAndGetData(nexCtx, s.URL.String())
+	if err != nil {
+		s.Error = null.StringFrom(err.Error())
+		return s, nil
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	s.MimeType = null.StringFrom(detectMimeType(s.URL.Path))
+
+	// As with Gemini, gemtext goes to `GemText`
+	// and everything else to `Data`.
+	switch s.MimeType.String {
+	case "text/gemini":
+		validBody, err := gemini.BytesToValidUTF8(data)
+		if err != nil {
+			s.Error = null.StringFrom(err.Error())
+			return s, nil
+		}
+		s.GemText = null.StringFrom(validBody)
+	case MimeTypeDirectory:
+		s.Data = null.ValueFrom(data)
+		if _, err := gemini.BytesToValidUTF8(data); err != nil {
+			s.Error = null.StringFrom(err.Error())
+			return s, nil
+		}
+	default:
+		s.Data = null.ValueFrom(data)
+	}
+
+	return s, nil
+}
+
+// Links returns the links of a Nex snapshot.
+// Directory listings and gemtext are the
+// documents with links.
+func Links(s *snapshot.Snapshot) linkList.LinkList {
+	if s.Error.Valid {
+		return nil
+	}
+	switch s.MimeType.ValueOrZero() {
+	case "text/gemini":
+		return gemini.Links(s)
+	case MimeTypeDirectory:
+		listing, err := gemini.BytesToValidUTF8(s.Data.ValueOrZero())
+		if err != nil {
+			return nil
+		}
+		return gemini.GetPageLinks(s.URL, listing)
+	default:
+		return nil
+	}
+}
+
+// ConnectAndGetData sends a Nex request for
+// the given URL and returns the raw response.
+func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
+	parsedURL, err := stdurl.Parse(url)
+	if err != nil {
+		return nil, xerrors.NewSimpleError(fmt.Errorf("error parsing URL: %w", err))
+	}
+
+	port := parsedURL.Port()
+	if port == "" {
+		port = "1900"
+	}
+	host := net.JoinHostPort(parsedURL.Hostname(), port)
+	return plaintext.Fetch(ctx, host, []byte(fmt.Sprintf("%s\r\n", requestPath(parsedURL))))
+}
+
+// requestPath returns the path to request for a
+// URL. Servers expect the root as an empty line.
+func requestPath(u *stdurl.URL) string {
+	p := u.EscapedPath()
+	if p == "/" {
+		p = ""
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+// detectMimeType returns the MIME type of a
+// Nex document, based on its path.
+func detectMimeType(urlPath string) string {
+	if urlPath == "" || strings.HasSuffix(urlPath, "/") {
+		return MimeTypeDirectory
+	}
+	ext := strings.ToLower(path.Ext(urlPath))
+	if ext == "" {
+		return "text/plain"
+	}
+	if mimeType, ok := extensionMimeTypes[ext]; ok {
+		return mimeType
+	}
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		return "application/octet-stream"
+	}
+	// Drop any parameters, e.g. charset
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
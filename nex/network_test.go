@@ -0,0 +1,124 @@
+package nex
+
+import (
+	"bufio"
+	"context"
+	"net"
+	stdurl "net/url"
+	"strings"
+	"testing"
+
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", MimeTypeDirectory},
+		{"/", MimeTypeDirectory},
+		{"/nex/", MimeTypeDirectory},
+		{"/about", "text/plain"},
+		{"/log/2024-01-01.txt", "text/plain"},
+		{"/index.gmi", "text/gemini"},
+		{"/README.MD", "text/markdown"},
+		{"/cat.png", "image/png"},
+		{"/index.html", "text/html"},
+		{"/archive.unknownext", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, detectMimeType(tt.path), tt.path)
+	}
+}
+
+func TestRequestPath(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"nex://nightfall.city", ""},
+		{"nex://nightfall.city/", ""},
+		{"nex://nightfall.city/nex/", "/nex/"},
+		{"nex://nightfall.city/search?cats", "/search?cats"},
+	}
+
+	for _, tt := range tests {
+		u, err := stdurl.Parse(tt.url)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, requestPath(u))
+	}
+}
+
+func TestVisit(t *testing.T) {
+	responses := map[string]string{
+		"": "Welcome!\n" +
+			"=> about.txt About\n" +
+			"=> /photos/ Photos\n" +
+			"=> gemini://example.com/ Elsewhere\n",
+		"/about.txt": "=> /not/a/link\n",
+		"/log.gmi":   "# Log\n=> entry.gmi First entry\n",
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			_, _ = conn.Write([]byte(responses[strings.TrimSpace(line)]))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	s, err := Visit(ctx, "nex://"+address+"/")
+	assert.NoError(t, err)
+	assert.False(t, s.Error.Valid)
+	assert.Equal(t, MimeTypeDirectory, s.MimeType.ValueOrZero())
+	assert.Equal(t, responses[""], string(s.Data.ValueOrZero()))
+	links := Links(s)
+	if assert.Len(t, links, 3) {
+		assert.Equal(t, "nex://"+address+"/about.txt", links[0].Full)
+		assert.Equal(t, "nex://"+address+"/photos/", links[1].Full)
+		assert.Equal(t, "gemini://example.com:1965/", links[2].Full)
+	}
+
+	// Links are only followed in directories
+	s, err = Visit(ctx, "nex://"+address+"/about.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", s.MimeType.ValueOrZero())
+	assert.Equal(t, responses["/about.txt"], string(s.Data.ValueOrZero()))
+	assert.Empty(t, Links(s))
+
+	// Gemtext is stored as such, with its links
+	s, err = Visit(ctx, "nex://"+address+"/log.gmi")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/gemini", s.MimeType.ValueOrZero())
+	assert.Equal(t, responses["/log.gmi"], s.GemText.ValueOrZero())
+	assert.False(t, s.Data.Valid)
+	links = Links(s)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "nex://"+address+"/entry.gmi", links[0].Full)
+	}
+}
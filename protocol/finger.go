@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"context"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	"gemini-grc/config"
+	"gemini-grc/finger"
+)
+
+// Finger has neither redirects nor robots.txt.
+
+type fingerHandler struct{}
+
+func (fingerHandler) Scheme() string { return "finger" }
+
+func (fingerHandler) DefaultPort() int { return defaultPort("finger") }
+
+func (fingerHandler) Enabled() bool { return config.CONFIG.FingerEnable }
+
+func (fingerHandler) Visit(ctx context.Context, url string) (*snapshot.Snapshot, error) {
+	return finger.Visit(ctx, url)
+}
+
+// Plans are plain text without links.
+func (fingerHandler) Links(*snapshot.Snapshot) linkList.LinkList { return nil }
+
+func (fingerHandler) IsRedirect(*snapshot.Snapshot) bool { return false }
+
+func (fingerHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/gemini"
+	"git.antanst.com/antanst/logging"
+)
+
+type geminiHandler struct{}
+
+func (geminiHandler) Scheme() string { return "gemini" }
+
+func (geminiHandler) DefaultPort() int { return defaultPort("gemini") }
+
+// Gemini is what we're here for, always on.
+func (geminiHandler) Enabled() bool { return true }
+
+func (geminiHandler) Visit(ctx context.Context, url string) (*snapshot.Snapshot, error) {
+	return gemini.Visit(ctx, url)
+}
+
+func (geminiHandler) Links(s *snapshot.Snapshot) linkList.LinkList {
+	return gemini.Links(s)
+}
+
+func (geminiHandler) IsRedirect(s *snapshot.Snapshot) bool {
+	code := s.ResponseCode.ValueOrZero()
+	return code >= 30 && code < 40
+}
+
+func (geminiHandler) IsPermanentRedirect(s *snapshot.Snapshot) bool {
+	return s.ResponseCode.ValueOrZero() == gemini.StatusPermanentRedirect
+}
+
+// FetchRobotsTxt returns the robots.txt contents
+// of a Gemini capsule, or an empty string if there is none.
+func (geminiHandler) FetchRobotsTxt(ctx context.Context, u *commonUrl.URL) (string, error) {
+	url := fmt.Sprintf("gemini://%s:%d/robots.txt", u.Hostname, u.Port)
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Fetching robots.txt from %s", url)
+
+	// Use the context-aware version to honor timeout and cancellation
+	robotsContent, err := gemini.ConnectAndGetData(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := snapshot.SnapshotFromURL(url, true)
+	if err != nil {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Failed to create snapshot from URL: %v", err)
+		return "", nil
+	}
+
+	s = gemini.UpdateSnapshotWithData(*s, robotsContent)
+
+	if s.ResponseCode.ValueOrZero() != 20 {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "robots.txt error code %d, ignoring", s.ResponseCode.ValueOrZero())
+		return "", nil
+	}
+
+	// Some return text/plain, others text/gemini.
+	// According to spec, the first is correct,
+	// however let's be lenient
+	switch {
+	case s.MimeType.ValueOrZero() == "text/plain":
+		return string(s.Data.ValueOrZero()), nil
+	case s.MimeType.ValueOrZero() == "text/gemini":
+		return s.GemText.ValueOrZero(), nil
+	default:
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Unsupported mime type: %s", s.MimeType.ValueOrZero())
+		return "", nil
+	}
+}
+
+func (geminiHandler) RobotsPath(u *commonUrl.URL) string {
+	return pathWithQuery(u)
+}
+
+// pathWithQuery returns the path of a URL
+// including any query, but not the fragment.
+func pathWithQuery(u *commonUrl.URL) string {
+	path := strings.TrimPrefix(u.Full, fmt.Sprintf("%s://%s:%d", u.Protocol, u.Hostname, u.Port))
+	path, _, _ = strings.Cut(path, "#")
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/config"
+	"gemini-grc/gopher"
+	"git.antanst.com/antanst/logging"
+)
+
+type gopherHandler struct{}
+
+func (gopherHandler) Scheme() string { return "gopher" }
+
+func (gopherHandler) DefaultPort() int { return defaultPort("gopher") }
+
+func (gopherHandler) Enabled() bool { return config.CONFIG.GopherEnable }
+
+func (gopherHandler) Visit(ctx context.Context, url string) (*snapshot.Snapshot, error) {
+	return gopher.VisitWithContext(ctx, url)
+}
+
+func (gopherHandler) Links(s *snapshot.Snapshot) linkList.LinkList {
+	return gopher.Links(s)
+}
+
+// Gopher has no redirects.
+func (gopherHandler) IsRedirect(*snapshot.Snapshot) bool { return false }
+
+func (gopherHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
+
+// FetchRobotsTxt returns the robots.txt contents
+// of a Gopher hole. By convention, it's a text file
+// with the "robots.txt" selector.
+func (gopherHandler) FetchRobotsTxt(ctx context.Context, u *commonUrl.URL) (string, error) {
+	url := fmt.Sprintf("gopher://%s:%d/0/robots.txt", u.Hostname, u.Port)
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Fetching robots.txt from %s", url)
+
+	robotsContent, err := gopher.ConnectAndGetDataWithContext(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	// Servers without a robots.txt usually reply with an
+	// error menu, which doesn't contain any rules anyway.
+	return string(robotsContent), nil
+}
+
+// RobotsPath returns the selector. Gopher rules refer
+// to selectors, so we drop the item type our gopher://
+// URLs carry in their path.
+func (gopherHandler) RobotsPath(u *commonUrl.URL) string {
+	return gopher.SelectorFromPath(u.Path)
+}
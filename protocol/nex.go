@@ -0,0 +1,32 @@
+package protocol
+
+import (
+	"context"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	"gemini-grc/config"
+	"gemini-grc/nex"
+)
+
+// Nex has neither redirects nor robots.txt.
+
+type nexHandler struct{}
+
+func (nexHandler) Scheme() string { return "nex" }
+
+func (nexHandler) DefaultPort() int { return defaultPort("nex") }
+
+func (nexHandler) Enabled() bool { return config.CONFIG.NexEnable }
+
+func (nexHandler) Visit(ctx context.Context, url string) (*snapshot.Snapshot, error) {
+	return nex.Visit(ctx, url)
+}
+
+func (nexHandler) Links(s *snapshot.Snapshot) linkList.LinkList {
+	return nex.Links(s)
+}
+
+func (nexHandler) IsRedirect(*snapshot.Snapshot) bool { return false }
+
+func (nexHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+)
+
+// Handler is what the crawler needs to know
+// about a protocol. Adding a protocol means
+// implementing a Handler and registering it,
+// everything else dispatches by URL scheme.
+type Handler interface {
+	// Scheme is the URL scheme, e.g. "gemini".
+	Scheme() string
+	// DefaultPort is used when a URL has no port.
+	DefaultPort() int
+	// Enabled reports if the configuration
+	// allows crawling this protocol.
+	Enabled() bool
+	// Visit fetches a URL and returns a populated
+	// snapshot, without links. Network and protocol
+	// errors are stored in the snapshot; an error is
+	// returned only when a snapshot can't be built.
+	Visit(ctx context.Context, url string) (*snapshot.Snapshot, error)
+	// Links extracts the links of a visited snapshot.
+	Links(s *snapshot.Snapshot) linkList.LinkList
+	// IsRedirect reports if the snapshot is a
+	// redirect with the target in its header.
+	IsRedirect(s *snapshot.Snapshot) bool
+	// IsPermanentRedirect reports if the snapshot is
+	// a redirect to where the URL moved for good.
+	IsPermanentRedirect(s *snapshot.Snapshot) bool
+}
+
+// RobotsHandler is implemented by the handlers
+// of protocols with a robots.txt convention.
+type RobotsHandler interface {
+	Handler
+	// FetchRobotsTxt returns the robots.txt of the
+	// host of u, or an empty string if there is none.
+	FetchRobotsTxt(ctx context.Context, u *commonUrl.URL) (string, error)
+	// RobotsPath returns the part of u that
+	// robots.txt rules are matched against.
+	RobotsPath(u *commonUrl.URL) string
+}
+
+var (
+	registry   = newRegistry(geminiHandler{}, gopherHandler{}, spartanHandler{}, nexHandler{}, fingerHandler{}) //nolint:gochecknoglobals
+	registryMu sync.RWMutex                                                                                     //nolint:gochecknoglobals
+)
+
+func newRegistry(handlers ...Handler) map[string]Handler {
+	r := make(map[string]Handler, len(handlers))
+	for _, h := range handlers {
+		register(r, h)
+	}
+	return r
+}
+
+// defaultPort returns the port of a
+// scheme from the common/url table.
+func defaultPort(scheme string) int {
+	port, _ := commonUrl.DefaultPort(scheme)
+	return port
+}
+
+func register(r map[string]Handler, h Handler) {
+	r[h.Scheme()] = h
+	commonUrl.RegisterDefaultPort(h.Scheme(), h.DefaultPort())
+}
+
+// Register adds a handler, replacing any
+// previous one for the same scheme.
+func Register(h Handler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	register(registry, h)
+}
+
+// Get returns the handler of a scheme.
+func Get(scheme string) (Handler, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	h, ok := registry[strings.ToLower(scheme)]
+	return h, ok
+}
+
+// ForURL returns the handler of a URL's scheme.
+func ForURL(u string) (Handler, bool) {
+	scheme, _, found := strings.Cut(u, "://")
+	if !found {
+		return nil, false
+	}
+	return Get(scheme)
+}
+
+// IsEnabled reports if a URL's protocol
+// is registered and enabled.
+func IsEnabled(u string) bool {
+	h, ok := ForURL(u)
+	return ok && h.Enabled()
+}
+
+// EnabledSchemes returns the enabled schemes, sorted.
+func EnabledSchemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var schemes []string
+	for scheme, h := range registry {
+		if h.Enabled() {
+			schemes = append(schemes, scheme)
+		}
+	}
+	slices.Sort(schemes)
+	return schemes
+}
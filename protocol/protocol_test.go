@@ -0,0 +1,196 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/config"
+	"github.com/guregu/null/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHandler struct{}
+
+func (testHandler) Scheme() string   { return "test" }
+func (testHandler) DefaultPort() int { return 4242 }
+func (testHandler) Enabled() bool    { return false }
+func (testHandler) Visit(_ context.Context, url string) (*snapshot.Snapshot, error) {
+	return snapshot.SnapshotFromURL(url, true)
+}
+func (testHandler) Links(*snapshot.Snapshot) linkList.LinkList  { return nil }
+func (testHandler) IsRedirect(*snapshot.Snapshot) bool          { return false }
+func (testHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
+
+func TestForURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url    string
+		scheme string
+		ok     bool
+	}{
+		{"gemini://example.com/", "gemini", true},
+		{"GOPHER://example.com/", "gopher", true},
+		{"spartan://example.com/", "spartan", true},
+		{"nex://example.com/", "nex", true},
+		{"finger://example.com/alice", "finger", true},
+		{"https://example.com/", "", false},
+		{"example.com", "", false},
+	}
+	for _, tt := range tests {
+		h, ok := ForURL(tt.url)
+		assert.Equal(t, tt.ok, ok, tt.url)
+		if ok {
+			assert.Equal(t, tt.scheme, h.Scheme(), tt.url)
+		}
+	}
+}
+
+func TestEnabledSchemes(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+
+	config.CONFIG = config.Config{}
+	assert.Equal(t, []string{"gemini"}, EnabledSchemes())
+	assert.True(t, IsEnabled("gemini://example.com/"))
+	assert.False(t, IsEnabled("gopher://example.com/"))
+
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.FingerEnable = true
+	assert.Equal(t, []string{"finger", "gemini", "gopher"}, EnabledSchemes())
+	assert.True(t, IsEnabled("gopher://example.com/"))
+	assert.False(t, IsEnabled("https://example.com/"))
+}
+
+func TestRegister(t *testing.T) {
+	r := newRegistry(testHandler{})
+
+	h, ok := r["test"]
+	assert.True(t, ok)
+	assert.Equal(t, "test", h.Scheme())
+
+	// The default port is known to URL parsing
+	u, err := commonUrl.ParseURL("test://example.com:4242/", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "test://example.com:4242/", u.Full)
+	assert.Equal(t, "test://example.com/", u.StringNoDefaultPort())
+}
+
+func TestIsRedirect(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		scheme    string
+		code      int64
+		want      bool
+		permanent bool
+	}{
+		{"gemini", 20, false, false},
+		{"gemini", 30, true, false},
+		{"gemini", 31, true, true},
+		{"gemini", 40, false, false},
+		{"spartan", 2, false, false},
+		{"spartan", 3, true, false},
+		{"gopher", 3, false, false},
+		{"nex", 3, false, false},
+	}
+	for _, tt := range tests {
+		h, ok := Get(tt.scheme)
+		assert.True(t, ok)
+		s := &snapshot.Snapshot{ResponseCode: null.IntFrom(tt.code)}
+		assert.Equal(t, tt.want, h.IsRedirect(s), "%s %d", tt.scheme, tt.code)
+		assert.Equal(t, tt.permanent, h.IsPermanentRedirect(s), "%s %d", tt.scheme, tt.code)
+	}
+}
+
+func TestDefaultPort(t *testing.T) {
+	t.Parallel()
+	tests := map[string]int{
+		"gemini":  1965,
+		"gopher":  70,
+		"spartan": 300,
+		"nex":     1900,
+		"finger":  79,
+	}
+
+	for scheme, port := range tests {
+		h, ok := Get(scheme)
+		if assert.True(t, ok, scheme) {
+			assert.Equal(t, port, h.DefaultPort(), scheme)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	t.Parallel()
+	s, err := snapshot.SnapshotFromURL("gemini://example.com/", true)
+	assert.NoError(t, err)
+	s.MimeType = null.StringFrom("text/gemini")
+	s.GemText = null.StringFrom("# Hi\n=> /about.gmi About\n")
+
+	gemini, _ := Get("gemini")
+	links := gemini.Links(s)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "gemini://example.com:1965/about.gmi", links[0].Full)
+	}
+
+	// No links in failed fetches
+	s.Error = null.StringFrom("error")
+	assert.Empty(t, gemini.Links(s))
+
+	s, err = snapshot.SnapshotFromURL("gopher://example.com/", true)
+	assert.NoError(t, err)
+	s.MimeType = null.StringFrom("text/x-gophermap")
+	s.Data = null.ValueFrom([]byte("1Phlog\t/phlog\texample.com\t70\r\n.\r\n"))
+
+	gopher, _ := Get("gopher")
+	links = gopher.Links(s)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "gopher://example.com:70/1/phlog", links[0].Full)
+	}
+
+	s, err = snapshot.SnapshotFromURL("finger://example.com/alice", true)
+	assert.NoError(t, err)
+	s.MimeType = null.StringFrom("text/plain")
+	s.Data = null.ValueFrom([]byte("=> gemini://example.com/\n"))
+
+	finger, _ := Get("finger")
+	assert.Empty(t, finger.Links(s))
+}
+
+func TestRobotsPath(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"gemini://example.com", "/"},
+		{"gemini://example.com/", "/"},
+		{"gemini://example.com/Docs/index.gmi", "/Docs/index.gmi"},
+		{"gemini://example.com/cgi-bin/search?query#top", "/cgi-bin/search?query"},
+		{"gopher://example.com/1/phlog", "/phlog"},
+		{"gopher://example.com/0/about.txt", "/about.txt"},
+		{"gopher://example.com/", "/"},
+		{"spartan://example.com/search?cats", "/search?cats"},
+	}
+	for _, tt := range tests {
+		u, err := commonUrl.ParseURL(tt.url, "", true)
+		if err != nil {
+			t.Fatalf("ParseURL(%s) failed: %v", tt.url, err)
+		}
+		h, ok := Get(u.Protocol)
+		if !ok {
+			t.Fatalf("No handler for %s", tt.url)
+		}
+		rh, ok := h.(RobotsHandler)
+		if !ok {
+			t.Fatalf("No robots.txt support for %s", tt.url)
+		}
+		if got := rh.RobotsPath(u); got != tt.want {
+			t.Errorf("RobotsPath(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
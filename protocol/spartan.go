@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"gemini-grc/common/contextlog"
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/snapshot"
+	commonUrl "gemini-grc/common/url"
+	"gemini-grc/config"
+	"gemini-grc/spartan"
+	"git.antanst.com/antanst/logging"
+)
+
+type spartanHandler struct{}
+
+func (spartanHandler) Scheme() string { return "spartan" }
+
+func (spartanHandler) DefaultPort() int { return defaultPort("spartan") }
+
+func (spartanHandler) Enabled() bool { return config.CONFIG.SpartanEnable }
+
+func (spartanHandler) Visit(ctx context.Context, url string) (*snapshot.Snapshot, error) {
+	return spartan.Visit(ctx, url)
+}
+
+func (spartanHandler) Links(s *snapshot.Snapshot) linkList.LinkList {
+	return spartan.Links(s)
+}
+
+func (spartanHandler) IsRedirect(s *snapshot.Snapshot) bool {
+	return s.ResponseCode.ValueOrZero() == spartan.StatusRedirect
+}
+
+// Spartan has a single redirect status,
+// we can't tell if a move is permanent.
+func (spartanHandler) IsPermanentRedirect(*snapshot.Snapshot) bool { return false }
+
+// FetchRobotsTxt returns the robots.txt
+// contents of a Spartan capsule.
+func (spartanHandler) FetchRobotsTxt(ctx context.Context, u *commonUrl.URL) (string, error) {
+	url := fmt.Sprintf("spartan://%s:%d/robots.txt", u.Hostname, u.Port)
+	contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Fetching robots.txt from %s", url)
+
+	robotsContent, err := spartan.ConnectAndGetData(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := snapshot.SnapshotFromURL(url, true)
+	if err != nil {
+		return "", nil
+	}
+	s = spartan.UpdateSnapshotWithData(*s, robotsContent)
+
+	if s.ResponseCode.ValueOrZero() != spartan.StatusSuccess {
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "robots.txt error code %d, ignoring", s.ResponseCode.ValueOrZero())
+		return "", nil
+	}
+	if s.MimeType.ValueOrZero() == "text/gemini" {
+		return s.GemText.ValueOrZero(), nil
+	}
+	return string(s.Data.ValueOrZero()), nil
+}
+
+func (spartanHandler) RobotsPath(u *commonUrl.URL) string {
+	return pathWithQuery(u)
+}
@@ -2,72 +2,153 @@ package robotsMatch
 
 import (
 	"context"
-	"fmt"
 	"strings"
 
 	"gemini-grc/common/contextlog"
+	"gemini-grc/config"
 	"gemini-grc/contextutil"
 	"git.antanst.com/antanst/logging"
 )
 
-// ParseRobotsTxt takes robots.txt content and a host, and
-// returns a list of full URLs that shouldn't be visited.
+// References:
+// gemini://geminiprotocol.net/docs/companion/robots.gmi
+// RFC 9309 https://www.rfc-editor.org/rfc/rfc9309.html
+
+// UserAgent is our own robots.txt user agent name.
+const UserAgent = "gemini-grc"
+
+// Virtual user agents defined by the Gemini robots.txt
+// companion spec. Bots should obey the rules of the
+// virtual agents that match what they do.
+const (
+	AgentArchiver   = "archiver"
+	AgentIndexer    = "indexer"
+	AgentResearcher = "researcher"
+)
+
+// RobotsRules holds the robots.txt path rules
+// that apply to our user agents.
+type RobotsRules struct {
+	Allow    []string `json:"allow,omitempty"`
+	Disallow []string `json:"disallow,omitempty"`
+}
+
+// UserAgents returns the user agents we obey
+// robots.txt rules for: our own name and the
+// virtual agent of the configured crawler mode.
+// Rules for `*` always apply.
+func UserAgents() []string {
+	mode := config.CONFIG.CrawlerMode
+	if mode == "" {
+		mode = AgentArchiver
+	}
+	return []string{UserAgent, mode}
+}
+
+// ParseRobotsTxt takes robots.txt content and the user
+// agents to obey, and returns the rules that apply.
 // This is the legacy version without context support.
-// TODO Also take into account the user agent?
-// Check gemini://geminiprotocol.net/docs/companion/robots.gmi
-func ParseRobotsTxt(content string, host string) []string {
+func ParseRobotsTxt(content string, agents []string) RobotsRules {
 	// Call the context-aware version with a background context
-	return ParseRobotsTxtWithContext(context.Background(), content, host)
+	return ParseRobotsTxtWithContext(context.Background(), content, agents)
 }
 
-// ParseRobotsTxtWithContext takes robots.txt content and a host, and
-// returns a list of full URLs that shouldn't be visited.
-// This version supports context for logging.
-// TODO Also take into account the user agent?
-// Check gemini://geminiprotocol.net/docs/companion/robots.gmi
-func ParseRobotsTxtWithContext(ctx context.Context, content string, host string) []string {
+// ParseRobotsTxtWithContext takes robots.txt content and the
+// user agents to obey, and returns the rules that apply.
+// Rules are collected from every group that names one
+// of the agents or `*`, as the Gemini companion spec says.
+// Directive names and agents are case-insensitive,
+// paths are not.
+func ParseRobotsTxtWithContext(ctx context.Context, content string, agents []string) RobotsRules {
 	// Create a context for robots.txt parsing
 	parseCtx := contextutil.ContextWithComponent(ctx, "robotsMatch.parser")
 
-	var disallowedPaths []string
+	var rules RobotsRules
+
+	// A group is one or more consecutive User-agent
+	// lines followed by rules.
+	groupMatches := false
+	inGroupRules := false
+
 	for _, line := range strings.Split(content, "\n") {
+		// Drop comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
 		line = strings.TrimSpace(line)
-		line = strings.ToLower(line)
-		if strings.HasPrefix(line, "disallow:") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				path := strings.TrimSpace(parts[1])
-				if path != "" {
-					// Construct full Gemini URL
-					var fullURL string
-
-					// Handle if the path is already a full URL
-					if strings.HasPrefix(path, "gemini://") {
-						// Extract just the path from the full URL
-						urlParts := strings.SplitN(path, "/", 4)
-						if len(urlParts) >= 4 {
-							// Get the path part (everything after the domain)
-							pathPart := "/" + urlParts[3]
-							fullURL = fmt.Sprintf("gemini://%s%s", host, pathPart)
-						} else {
-							// If it's just a domain without a path, skip it or use root path
-							fullURL = fmt.Sprintf("gemini://%s/", host)
-						}
-					} else {
-						// It's a relative path, just add it to the host
-						if !strings.HasPrefix(path, "/") {
-							path = "/" + path
-						}
-						fullURL = fmt.Sprintf("gemini://%s%s", host, path)
-					}
-
-					disallowedPaths = append(disallowedPaths, fullURL)
-
-					// Add additional logging to debug robots.txt parsing
-					contextlog.LogDebugWithContext(parseCtx, logging.GetSlogger(), "Added robots.txt disallow rule: %s from original: %s", fullURL, path)
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inGroupRules {
+				// Start of a new group
+				groupMatches = false
+				inGroupRules = false
+			}
+			if matchesAgent(value, agents) {
+				groupMatches = true
+			}
+		case "allow", "disallow":
+			inGroupRules = true
+			// An empty rule matches nothing.
+			if !groupMatches || value == "" {
+				continue
+			}
+			// Some write full URLs, keep just the path.
+			if _, afterScheme, ok := strings.Cut(value, "://"); ok {
+				value = "/"
+				if _, path, ok := strings.Cut(afterScheme, "/"); ok {
+					value += path
 				}
 			}
+			if !strings.HasPrefix(value, "/") {
+				value = "/" + value
+			}
+			if key == "allow" {
+				rules.Allow = append(rules.Allow, value)
+			} else {
+				rules.Disallow = append(rules.Disallow, value)
+			}
+			contextlog.LogDebugWithContext(parseCtx, logging.GetSlogger(), "Added robots.txt %s rule: %s", key, value)
+		}
+	}
+	return rules
+}
+
+func matchesAgent(agent string, agents []string) bool {
+	if agent == "*" {
+		return true
+	}
+	for _, a := range agents {
+		if strings.EqualFold(agent, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked checks if a path (including any query)
+// is disallowed. The longest matching rule wins,
+// and Allow wins ties.
+func (r RobotsRules) IsBlocked(path string) bool {
+	longestAllow := longestMatch(r.Allow, path)
+	longestDisallow := longestMatch(r.Disallow, path)
+	return longestDisallow > longestAllow
+}
+
+// longestMatch returns the length of the longest
+// rule that is a prefix of path, or -1 if none is.
+func longestMatch(rules []string, path string) int {
+	longest := -1
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule) && len(rule) > longest {
+			longest = len(rule)
 		}
 	}
-	return disallowedPaths
+	return longest
 }
@@ -2,133 +2,320 @@ package robotsMatch
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"gemini-grc/common/contextlog"
-	"gemini-grc/common/snapshot"
 	geminiUrl "gemini-grc/common/url"
 	"gemini-grc/config"
 	"gemini-grc/contextutil"
-	"gemini-grc/gemini"
+	gemdb "gemini-grc/db"
+	"gemini-grc/hostPool"
+	"gemini-grc/protocol"
 	"git.antanst.com/antanst/logging"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/guregu/null/v5"
+	"github.com/jmoiron/sqlx"
 )
 
-// RobotsCache is a map of blocked URLs
-// key: URL
-// value: []string list of disallowed URLs
-// If a key has no blocked URLs, an empty
-// list is stored for caching.
+// RobotsCache is a map of robots.txt rules
+// key: scheme://host:port
+// value: cachedRobots with the RobotsRules that apply to us
+// If a key has no rules, empty rules
+// are stored for caching.
 var RobotsCache sync.Map //nolint:gochecknoglobals
 
-func populateRobotsCache(ctx context.Context, key string) (entries []string, _err error) {
+// persistCache is set when the robots.txt cache is
+// backed by the `robots` table. Tests and tools that
+// run without a database leave it unset.
+var persistCache atomic.Bool //nolint:gochecknoglobals
+
+// How often the background refresher looks for
+// expired entries, and how many it refreshes per run.
+const (
+	refreshInterval  = 10 * time.Minute
+	refreshBatchSize = 100
+)
+
+type cachedRobots struct {
+	Rules     RobotsRules
+	ExpiresAt time.Time
+}
+
+// EnablePersistence backs the robots.txt cache with the
+// database. Must be called after the database is initialized.
+func EnablePersistence() {
+	persistCache.Store(true)
+}
+
+func robotsTTL() time.Duration {
+	return time.Duration(config.CONFIG.RobotsCacheTTLHours) * time.Hour
+}
+
+// populateRobotsCache uses the caller's transaction for
+// the persistent cache, since the SQLite database has a
+// single connection that the caller already holds.
+func populateRobotsCache(ctx context.Context, tx *sqlx.Tx, u *geminiUrl.URL, key string) (RobotsRules, error) {
 	// Create a context for robots cache population
 	cacheCtx := contextutil.ContextWithComponent(ctx, "robotsCache")
 
-	// We either store an empty list when
-	// no rules, or a list of disallowed URLs.
-	// This applies even if we have an error
-	// finding/downloading robots.txt
-	defer func() {
-		RobotsCache.Store(key, entries)
-	}()
-
-	url := fmt.Sprintf("gemini://%s/robots.txt", key)
-	contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "Fetching robots.txt from %s", url)
+	// Try the persistent cache before hitting the host.
+	var previous *gemdb.RobotsEntry
+	if persistCache.Load() {
+		var err error
+		previous, err = gemdb.Database.GetRobotsEntry(cacheCtx, tx, key)
+		if err != nil {
+			contextlog.LogErrorWithContext(cacheCtx, logging.GetSlogger(), "Failed to load robots.txt from database: %v", err)
+		}
+		if previous != nil && time.Now().Before(previous.ExpiresAt) {
+			rules := rulesFromEntry(cacheCtx, previous)
+			RobotsCache.Store(key, cachedRobots{Rules: rules, ExpiresAt: previous.ExpiresAt})
+			return rules, nil
+		}
+	}
 
-	// Use the context-aware version to honor timeout and cancellation
-	robotsContent, err := gemini.ConnectAndGetData(cacheCtx, url)
+	rules, entry, err := refreshRobots(cacheCtx, u, key, previous)
 	if err != nil {
+		return RobotsRules{}, err
+	}
+	if persistCache.Load() && entry != nil {
+		if saveErr := gemdb.Database.SaveRobotsEntry(cacheCtx, tx, entry); saveErr != nil {
+			contextlog.LogErrorWithContext(cacheCtx, logging.GetSlogger(), "Failed to save robots.txt to database: %v", saveErr)
+		}
+	}
+	return rules, nil
+}
+
+// refreshRobots fetches the robots.txt of a host and
+// stores its rules in the cache. If the fetch fails,
+// the previously stored rules are kept, or empty rules
+// are stored to avoid continually hitting the host.
+// It returns the entry the caller should persist, if any.
+func refreshRobots(ctx context.Context, u *geminiUrl.URL, key string, previous *gemdb.RobotsEntry) (RobotsRules, *gemdb.RobotsEntry, error) {
+	h, ok := robotsHandler(u)
+	if !ok {
+		return RobotsRules{}, nil, nil
+	}
+	data, err := h.FetchRobotsTxt(ctx, u)
+
+	now := time.Now()
+	entry := &gemdb.RobotsEntry{
+		HostKey:   key,
+		FetchedAt: now,
+		ExpiresAt: now.Add(robotsTTL()),
+	}
+
+	var rules RobotsRules
+	switch {
+	case err != nil:
 		// Check for context timeout or cancellation specifically
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "Timeout or cancellation while fetching robots.txt: %v", err)
+			contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Timeout or cancellation while fetching robots.txt: %v", err)
 			// Don't cache the result on timeout, to allow retrying later
-			return []string{}, err
+			return RobotsRules{}, nil, err
 		}
-		// For other errors, we store an empty list for this host
-		// to avoid continually hitting it
-		contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "Failed to get robots.txt: %v", err)
-		RobotsCache.Store(key, []string{})
-		return []string{}, err
+		contextlog.LogDebugWithContext(ctx, logging.GetSlogger(), "Failed to get robots.txt: %v", err)
+		entry.Status = gemdb.RobotsStatusError
+		if previous != nil {
+			entry.Body = previous.Body
+			rules = rulesFromEntry(ctx, previous)
+		}
+	case data == "":
+		entry.Status = gemdb.RobotsStatusMissing
+	default:
+		entry.Status = gemdb.RobotsStatusOK
+		entry.Body = null.StringFrom(data)
+		rules = ParseRobotsTxtWithContext(ctx, data, UserAgents())
 	}
 
-	s, err := snapshot.SnapshotFromURL(url, true)
+	marshalled, err := json.Marshal(rules)
 	if err != nil {
-		contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "Failed to create snapshot from URL: %v", err)
-		return []string{}, nil
+		return RobotsRules{}, nil, xerrors.NewError(fmt.Errorf("cannot serialize robots.txt rules for %s: %w", key, err), 0, "", false)
 	}
+	entry.Rules = null.ValueFrom(marshalled)
+
+	RobotsCache.Store(key, cachedRobots{Rules: rules, ExpiresAt: entry.ExpiresAt})
+
+	return rules, entry, nil
+}
 
-	s = gemini.UpdateSnapshotWithData(*s, robotsContent)
+// rulesFromEntry parses the stored robots.txt body for
+// the current user agents. The stored rules were parsed
+// for the crawler mode at fetch time, which may differ.
+func rulesFromEntry(ctx context.Context, e *gemdb.RobotsEntry) RobotsRules {
+	if !e.Body.Valid {
+		return RobotsRules{}
+	}
+	return ParseRobotsTxtWithContext(ctx, e.Body.String, UserAgents())
+}
 
-	if s.ResponseCode.ValueOrZero() != 20 {
-		contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "robots.txt error code %d, ignoring", s.ResponseCode.ValueOrZero())
-		return []string{}, nil
+func loadRobotsEntry(ctx context.Context, key string) (*gemdb.RobotsEntry, error) {
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return nil, err
 	}
+	entry, err := gemdb.Database.GetRobotsEntry(ctx, tx, key)
+	if err != nil {
+		_ = gemdb.SafeRollback(ctx, tx)
+		return nil, err
+	}
+	return entry, tx.Commit()
+}
 
-	// Some return text/plain, others text/gemini.
-	// According to spec, the first is correct,
-	// however let's be lenient
-	var data string
-	switch {
-	case s.MimeType.ValueOrZero() == "text/plain":
-		data = string(s.Data.ValueOrZero())
-	case s.MimeType.ValueOrZero() == "text/gemini":
-		data = s.GemText.ValueOrZero()
-	default:
-		contextlog.LogDebugWithContext(cacheCtx, logging.GetSlogger(), "Unsupported mime type: %s", s.MimeType.ValueOrZero())
-		return []string{}, nil
+func saveRobotsEntry(ctx context.Context, entry *gemdb.RobotsEntry) error {
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+	err = gemdb.Database.SaveRobotsEntry(ctx, tx, entry)
+	if err != nil {
+		_ = gemdb.SafeRollback(ctx, tx)
+		return err
 	}
+	return tx.Commit()
+}
 
-	entries = ParseRobotsTxtWithContext(ctx, data, key)
-	return entries, nil
+// RunCacheRefresher periodically refreshes expired entries
+// of the persistent robots.txt cache, so that rule changes
+// are picked up without waiting for a crawl of the host.
+// Runs until ctx is canceled, should be called as a goroutine.
+func RunCacheRefresher(ctx context.Context) {
+	ctx = contextutil.ContextWithComponent(ctx, "robotsRefresher")
+	for {
+		count, err := refreshExpiredRobots(ctx)
+		if err != nil && ctx.Err() == nil {
+			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Failed to refresh robots.txt cache: %v", err)
+		} else if count > 0 {
+			contextlog.LogInfoWithContext(ctx, logging.GetSlogger(), "Refreshed %d expired robots.txt entries", count)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refreshInterval):
+		}
+	}
+}
+
+func refreshExpiredRobots(ctx context.Context) (int, error) {
+	if !persistCache.Load() {
+		return 0, nil
+	}
+
+	tx, err := gemdb.Database.NewTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := gemdb.Database.GetExpiredRobotsKeys(ctx, tx, refreshBatchSize)
+	if err != nil {
+		_ = gemdb.SafeRollback(ctx, tx)
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		u, err := geminiUrl.ParseURL(key, "", true)
+		if err != nil {
+			contextlog.LogErrorWithContext(ctx, logging.GetSlogger(), "Invalid robots.txt host key %s: %v", key, err)
+			continue
+		}
+		previous, err := loadRobotsEntry(ctx, key)
+		if err != nil {
+			return count, err
+		}
+		// Take the host like a worker does, so the
+		// fetch respects its request interval. No
+		// transaction is open meanwhile, so that
+		// SQLite workers aren't kept waiting.
+		if err := hostPool.AddHostToHostPool(ctx, u.Hostname); err != nil {
+			return count, err
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, time.Duration(config.CONFIG.ForHost(u.Hostname).ResponseTimeout)*time.Second)
+		_, entry, _ := refreshRobots(fetchCtx, u, key, previous)
+		cancel()
+		hostPool.RemoveHostFromPool(ctx, u.Hostname)
+		if entry != nil {
+			if err := saveRobotsEntry(ctx, entry); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// robotsHandler returns the handler of a URL's
+// protocol, if the protocol has robots.txt.
+func robotsHandler(u *geminiUrl.URL) (protocol.RobotsHandler, bool) {
+	h, ok := protocol.Get(u.Protocol)
+	if !ok {
+		return nil, false
+	}
+	rh, ok := h.(protocol.RobotsHandler)
+	return rh, ok
 }
 
 // RobotMatch checks if the snapshot URL matches
-// a robots.txt allow rule.
-func RobotMatch(ctx context.Context, u string) bool {
+// a robots.txt allow rule. The persistent cache is
+// read and written within tx.
+func RobotMatch(ctx context.Context, tx *sqlx.Tx, u string) bool {
 	// Create a context for robots operations
 	robotsCtx := contextutil.ContextWithComponent(ctx, "robotsMatch")
 
-	// TODO Missing Gopher functionality
-	if config.CONFIG.GopherEnable {
+	url, err := geminiUrl.ParseURL(u, "", true)
+	if err != nil {
 		return false
 	}
 
-	url, err := geminiUrl.ParseURL(u, "", true)
-	if err != nil {
+	h, ok := robotsHandler(url)
+	if !ok {
 		return false
 	}
 
-	key := strings.ToLower(fmt.Sprintf("%s:%d", url.Hostname, url.Port))
+	key := strings.ToLower(fmt.Sprintf("%s://%s:%d", url.Protocol, url.Hostname, url.Port))
 	contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "Checking robots.txt for URL: %s with host key: %s", u, key)
 
-	var disallowedURLs []string
+	var rules RobotsRules
 	cacheEntries, ok := RobotsCache.Load(key)
+	if ok {
+		if cached, isCached := cacheEntries.(cachedRobots); isCached && time.Now().After(cached.ExpiresAt) {
+			contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "robots.txt cache for %s expired", key)
+			ok = false
+		}
+	}
 	if !ok {
 		// First time check, populate robot cache
 		contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "No robots.txt cache for %s, fetching...", key)
 		var fetchErr error
-		disallowedURLs, fetchErr = populateRobotsCache(ctx, key)
+		rules, fetchErr = populateRobotsCache(ctx, tx, url, key)
 		if fetchErr != nil {
 			return false
 		}
-		if len(disallowedURLs) > 0 {
-			contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "Added to robots.txt cache: %v => %v", key, disallowedURLs)
+		if len(rules.Disallow) > 0 {
+			contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "Added to robots.txt cache: %v => %v", key, rules)
 		} else {
 			contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "No disallowed paths found in robots.txt for %s", key)
 		}
 	} else {
-		var ok bool
-		disallowedURLs, ok = cacheEntries.([]string)
+		cached, ok := cacheEntries.(cachedRobots)
 		if !ok {
 			contextlog.LogErrorWithContext(robotsCtx, logging.GetSlogger(), "Invalid type in robots.txt cache for %s", key)
-			disallowedURLs = []string{} // Use empty list as fallback
 		}
-		contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "Found %d disallowed paths in robots.txt cache for %s", len(disallowedURLs), key)
+		rules = cached.Rules // Empty rules as fallback
+		contextlog.LogDebugWithContext(robotsCtx, logging.GetSlogger(), "Found %d disallowed paths in robots.txt cache for %s", len(rules.Disallow), key)
 	}
-	return isURLblocked(ctx, disallowedURLs, url.Full)
+	return isURLblocked(ctx, rules, h.RobotsPath(url))
 }
 
 // Initialize initializes the robots.txt match package
@@ -143,19 +330,14 @@ func Shutdown() error {
 	return nil
 }
 
-func isURLblocked(ctx context.Context, disallowedURLs []string, input string) bool {
+func isURLblocked(ctx context.Context, rules RobotsRules, path string) bool {
 	// Create a context for URL blocking checks
 	blockCtx := contextutil.ContextWithComponent(ctx, "robotsMatch.isURLblocked")
 
-	inputLower := strings.ToLower(input)
-
-	for _, url := range disallowedURLs {
-		urlLower := strings.ToLower(url)
-		if strings.HasPrefix(inputLower, urlLower) {
-			contextlog.LogDebugWithContext(blockCtx, logging.GetSlogger(), "MATCH! robots.txt rule: %s blocks URL: %s", url, input)
-			return true
-		}
+	if rules.IsBlocked(path) {
+		contextlog.LogDebugWithContext(blockCtx, logging.GetSlogger(), "MATCH! robots.txt rules %v block path: %s", rules, path)
+		return true
 	}
-	contextlog.LogDebugWithContext(blockCtx, logging.GetSlogger(), "No robots.txt rules matched URL: %s", input)
+	contextlog.LogDebugWithContext(blockCtx, logging.GetSlogger(), "No robots.txt rules matched path: %s", path)
 	return false
 }
@@ -1,11 +1,17 @@
 package robotsMatch
 
 import (
+	"bufio"
 	"context"
+	"net"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"gemini-grc/config"
+	gemdb "gemini-grc/db"
+	"github.com/guregu/null/v5"
 )
 
 func TestInitializeShutdown(t *testing.T) {
@@ -31,10 +37,185 @@ func TestRobotMatch_EmptyCache(t *testing.T) {
 
 	// For empty cache or DNS errors, RobotMatch should return false (allow the URL) without an error
 	ctx := context.Background()
-	blocked := RobotMatch(ctx, "gemini://nonexistent.example.com/")
+	blocked := RobotMatch(ctx, nil, "gemini://nonexistent.example.com/")
 
 	// The URL should be allowed (not blocked) when robots.txt can't be fetched
 	if blocked {
 		t.Errorf("Expected URL to be allowed when robots.txt can't be fetched")
 	}
 }
+
+// startFakeGopherServer serves the given selector => response
+// map, and returns the server address.
+func startFakeGopherServer(t *testing.T, responses map[string]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			request, _ := bufio.NewReader(conn).ReadString('\n')
+			response, ok := responses[strings.TrimSpace(request)]
+			if !ok {
+				response = "3Not found\t\terror.host\t1\r\n.\r\n"
+			}
+			_, _ = conn.Write([]byte(response))
+			_ = conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRobotMatch_Gopher(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	RobotsCache = sync.Map{}
+
+	address := startFakeGopherServer(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /private\n",
+	})
+
+	ctx := context.Background()
+	if !RobotMatch(ctx, nil, "gopher://"+address+"/1/private/stuff") {
+		t.Errorf("Expected Gopher menu under /private to be blocked")
+	}
+	if !RobotMatch(ctx, nil, "gopher://"+address+"/0/private.txt") {
+		t.Errorf("Expected Gopher text file /private.txt to be blocked")
+	}
+	if RobotMatch(ctx, nil, "gopher://"+address+"/1/public") {
+		t.Errorf("Expected Gopher menu under /public to be allowed")
+	}
+}
+
+func TestRobotMatch_GopherNoRobotsTxt(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	RobotsCache = sync.Map{}
+
+	address := startFakeGopherServer(t, map[string]string{})
+
+	if RobotMatch(context.Background(), nil, "gopher://"+address+"/1/anything") {
+		t.Errorf("Expected URL to be allowed when the Gopher hole has no robots.txt")
+	}
+}
+
+func TestRobotMatch_GeminiWithGopherEnabled(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+
+	RobotsCache = sync.Map{}
+	RobotsCache.Store("gemini://example.com:1965", cachedRobots{
+		Rules:     RobotsRules{Disallow: []string{"/private"}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	if !RobotMatch(context.Background(), nil, "gemini://example.com/private/page.gmi") {
+		t.Errorf("Expected Gemini robots.txt to be enforced when Gopher is enabled")
+	}
+}
+
+func TestRobotMatch_ExpiredCache(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.GopherEnable = true
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+	config.CONFIG.RobotsCacheTTLHours = 24
+
+	RobotsCache = sync.Map{}
+
+	address := startFakeGopherServer(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /new-rule\n",
+	})
+	key := "gopher://" + address
+
+	// A fresh entry is used as is, without hitting the host.
+	RobotsCache.Store(key, cachedRobots{
+		Rules:     RobotsRules{Disallow: []string{"/old-rule"}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	ctx := context.Background()
+	if !RobotMatch(ctx, nil, key+"/1/old-rule") {
+		t.Errorf("Expected cached rule to be used")
+	}
+
+	// An expired entry is fetched again.
+	RobotsCache.Store(key, cachedRobots{
+		Rules:     RobotsRules{Disallow: []string{"/old-rule"}},
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if RobotMatch(ctx, nil, key+"/1/old-rule") {
+		t.Errorf("Expected expired rule to be dropped")
+	}
+	if !RobotMatch(ctx, nil, key+"/1/new-rule") {
+		t.Errorf("Expected refreshed rule to be used")
+	}
+
+	cached, _ := RobotsCache.Load(key)
+	if !cached.(cachedRobots).ExpiresAt.After(time.Now().Add(23 * time.Hour)) {
+		t.Errorf("Expected refreshed entry to expire after the configured TTL")
+	}
+}
+
+func TestRulesFromEntryUsesCrawlerMode(t *testing.T) {
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+
+	entry := &gemdb.RobotsEntry{
+		HostKey: "gemini://example.com:1965",
+		Status:  gemdb.RobotsStatusOK,
+		Body:    null.StringFrom("User-agent: archiver\nDisallow: /archive\n\nUser-agent: indexer\nDisallow: /index\n"),
+		// Parsed while crawling as an archiver
+		Rules: null.ValueFrom([]byte(`{"disallow":["/archive"]}`)),
+	}
+
+	config.CONFIG.CrawlerMode = AgentIndexer
+	rules := rulesFromEntry(context.Background(), entry)
+	if !rules.IsBlocked("/index") || rules.IsBlocked("/archive") {
+		t.Errorf("Expected indexer rules, got %v", rules)
+	}
+}
+
+func TestRunCacheRefresherStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunCacheRefresher(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunCacheRefresher did not stop after cancel")
+	}
+}
@@ -14,13 +14,14 @@ Disallow: /cgi-bin/wp.cgi/media
 User-agent: googlebot
 Disallow: /admin/`
 
-	expected := []string{
-		"gemini://example.com/cgi-bin/wp.cgi/view",
-		"gemini://example.com/cgi-bin/wp.cgi/media",
-		"gemini://example.com/admin/",
+	expected := RobotsRules{
+		Disallow: []string{
+			"/cgi-bin/wp.cgi/view",
+			"/cgi-bin/wp.cgi/media",
+		},
 	}
 
-	result := ParseRobotsTxt(input, "example.com")
+	result := ParseRobotsTxt(input, []string{UserAgent, AgentArchiver})
 
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("ParseRobotsTxt() = %v, want %v", result, expected)
@@ -31,27 +32,146 @@ func TestParseRobotsTxtEmpty(t *testing.T) {
 	t.Parallel()
 	input := ``
 
-	result := ParseRobotsTxt(input, "example.com")
+	result := ParseRobotsTxt(input, []string{UserAgent, AgentArchiver})
 
-	if len(result) != 0 {
-		t.Errorf("ParseRobotsTxt() = %v, want empty []string", result)
+	if len(result.Allow) != 0 || len(result.Disallow) != 0 {
+		t.Errorf("ParseRobotsTxt() = %v, want empty rules", result)
+	}
+}
+
+func TestParseRobotsTxtUserAgents(t *testing.T) {
+	t.Parallel()
+	input := `# Keep archivers out of the logs
+User-agent: archiver
+User-agent: researcher
+Disallow: /logs/
+
+User-agent: indexer
+Disallow: /
+
+user-agent: GEMINI-GRC
+disallow: /private/ # our own rules
+allow: /private/public/
+
+User-agent: webproxy
+Disallow: /`
+
+	tests := []struct {
+		name   string
+		agents []string
+		want   RobotsRules
+	}{
+		{
+			name:   "Archiver",
+			agents: []string{UserAgent, AgentArchiver},
+			want: RobotsRules{
+				Allow:    []string{"/private/public/"},
+				Disallow: []string{"/logs/", "/private/"},
+			},
+		},
+		{
+			name:   "Indexer",
+			agents: []string{UserAgent, AgentIndexer},
+			want: RobotsRules{
+				Allow:    []string{"/private/public/"},
+				Disallow: []string{"/", "/private/"},
+			},
+		},
+		{
+			name:   "Other bot",
+			agents: []string{"otherbot", AgentResearcher},
+			want: RobotsRules{
+				Disallow: []string{"/logs/"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := ParseRobotsTxt(input, tt.agents)
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("ParseRobotsTxt() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRobotsTxtFullURLs(t *testing.T) {
+	t.Parallel()
+	input := `User-agent: *
+Disallow: gemini://example.com/private
+Disallow: gemini://example.com`
+
+	expected := RobotsRules{
+		Disallow: []string{"/private", "/"},
+	}
+
+	result := ParseRobotsTxt(input, []string{UserAgent})
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseRobotsTxt() = %v, want %v", result, expected)
+	}
+}
+
+func TestRobotsRulesIsBlocked(t *testing.T) {
+	t.Parallel()
+	rules := RobotsRules{
+		Allow:    []string{"/private/public/", "/Docs"},
+		Disallow: []string{"/private/", "/docs", "/cgi-bin/search"},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", false},
+		{"/index.gmi", false},
+		{"/private/", true},
+		{"/private/diary.gmi", true},
+		{"/private/public/", false},
+		{"/private/public/hello.gmi", false},
+		{"/cgi-bin/search?query", true},
+		// Paths are case-sensitive
+		{"/docs/index.gmi", true},
+		{"/Docs/index.gmi", false},
+		{"/PRIVATE/diary.gmi", false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.IsBlocked(tt.path); got != tt.want {
+			t.Errorf("IsBlocked(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulesAllowWinsTies(t *testing.T) {
+	t.Parallel()
+	rules := RobotsRules{
+		Allow:    []string{"/page"},
+		Disallow: []string{"/page"},
+	}
+	if rules.IsBlocked("/page") {
+		t.Errorf("Expected Allow to win over Disallow of the same length")
 	}
 }
 
 func TestIsURLblocked(t *testing.T) {
 	t.Parallel()
-	disallowedURLs := []string{
-		"gemini://example.com/cgi-bin/wp.cgi/view",
-		"gemini://example.com/cgi-bin/wp.cgi/media",
-		"gemini://example.com/admin/",
+	rules := RobotsRules{
+		Disallow: []string{
+			"/cgi-bin/wp.cgi/view",
+			"/cgi-bin/wp.cgi/media",
+			"/admin/",
+		},
 	}
 	ctx := context.Background()
-	url := "gemini://example.com/admin/index.html"
-	if !isURLblocked(ctx, disallowedURLs, url) {
-		t.Errorf("Expected %s to be blocked", url)
+	path := "/admin/index.html"
+	if !isURLblocked(ctx, rules, path) {
+		t.Errorf("Expected %s to be blocked", path)
 	}
-	url = "gemini://example1.com/admin/index.html"
-	if isURLblocked(ctx, disallowedURLs, url) {
-		t.Errorf("expected %s to not be blocked", url)
+	path = "/public/admin/index.html"
+	if isURLblocked(ctx, rules, path) {
+		t.Errorf("expected %s to not be blocked", path)
 	}
 }
@@ -0,0 +1,193 @@
+package spartan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	stdurl "net/url"
+	"strconv"
+	"strings"
+
+	"gemini-grc/common/linkList"
+	"gemini-grc/common/plaintext"
+	"gemini-grc/common/snapshot"
+	"gemini-grc/contextutil"
+	"gemini-grc/gemini"
+	"git.antanst.com/antanst/xerrors"
+	"github.com/guregu/null/v5"
+)
+
+// References:
+// spartan://mozz.us/specification.gmi
+
+// Spartan is a plaintext protocol on port 300.
+// A request is a single line `host path content-length`,
+// optionally followed by content-length bytes of data.
+// A response is a `status meta` header line followed
+// by the body, with status one of:
+//
+// `2` - Success, meta is the MIME type
+// `3` - Redirect, meta is an absolute path on the same host
+// `4` - Client error, meta is an error message
+// `5` - Server error, meta is an error message
+//
+// Bodies are usually gemtext, with one addition:
+// `=: URL` lines are prompts asking for user input,
+// which we don't follow.
+
+const (
+	StatusSuccess     = 2
+	StatusRedirect    = 3
+	StatusClientError = 4
+	StatusServerError = 5
+)
+
+// Visit visits a given URL using the Spartan protocol,
+// and returns a populated snapshot. As with Gemini,
+// network and protocol errors are stored in the snapshot,
+// and an error is returned only when a snapshot
+// could not be constructed.
+func Visit(ctx context.Context, url string) (s *snapshot.Snapshot, err error) {
+	spartanCtx := contextutil.ContextWithComponent(ctx, "spartan")
+
+	s, err = snapshot.SnapshotFromURL(url, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	data, err := ConnectAndGetData(spartanCtx, s.URL.String())
+	if err != nil {
+		s.Error = null.StringFrom(err.Error())
+		return s, nil
+	}
+
+	// Check if the context has been canceled
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.NewSimpleError(err)
+	}
+
+	s = UpdateSnapshotWithData(*s, data)
+
+	return s, nil
+}
+
+// Links returns the links of a Spartan snapshot.
+// Gemtext link lines (`=>`) are the same in Spartan.
+// Prompt lines (`=:`) don't match and are skipped.
+func Links(s *snapshot.Snapshot) linkList.LinkList {
+	return gemini.Links(s)
+}
+
+// ConnectAndGetData sends a Spartan request for the
+// given URL and returns the raw response. A query
+// string in the URL is sent as the request data.
+func ConnectAndGetData(ctx context.Context, url string) ([]byte, error) {
+	parsedURL, err := stdurl.Parse(url)
+	if err != nil {
+		return nil, xerrors.NewSimpleError(fmt.Errorf("error parsing URL: %w", err))
+	}
+
+	port := parsedURL.Port()
+	if port == "" {
+		port = "300"
+	}
+	host := net.JoinHostPort(parsedURL.Hostname(), port)
+	return plaintext.Fetch(ctx, host, buildRequest(parsedURL))
+}
+
+// buildRequest builds the request line for a URL,
+// followed by the decoded query as data, if any.
+func buildRequest(u *stdurl.URL) []byte {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	data, err := stdurl.QueryUnescape(u.RawQuery)
+	if err != nil {
+		data = u.RawQuery
+	}
+	request := fmt.Sprintf("%s %s %d\r\n%s", u.Hostname(), path, len(data), data)
+	return []byte(request)
+}
+
+// UpdateSnapshotWithData processes the raw data
+// from a Spartan response and populates the Snapshot.
+func UpdateSnapshotWithData(s snapshot.Snapshot, data []byte) *snapshot.Snapshot {
+	header, body, found := strings.Cut(string(data), "\n")
+	if !found {
+		s.Error = null.StringFrom(xerrors.NewSimpleError(fmt.Errorf("error parsing header")).Error())
+		return &s
+	}
+	header = strings.TrimSpace(header)
+	s.Header = null.StringFrom(header)
+
+	code, meta, err := parseHeader(header)
+	if err != nil {
+		s.Error = null.StringFrom(err.Error())
+		return &s
+	}
+	s.ResponseCode = null.IntFrom(int64(code))
+
+	switch code {
+	case StatusSuccess:
+	case StatusRedirect:
+		return &s
+	default:
+		s.Error = null.StringFrom(fmt.Sprintf("spartan error: %d %s", code, meta))
+		return &s
+	}
+
+	mimeType, lang := parseMimeType(meta)
+	s.MimeType = null.StringFrom(mimeType)
+	if lang != "" {
+		s.Lang = null.StringFrom(lang)
+	}
+
+	// As with Gemini, gemtext goes to `GemText`
+	// and everything else to `Data`.
+	if mimeType == "text/gemini" {
+		validBody, err := gemini.BytesToValidUTF8([]byte(body))
+		if err != nil {
+			s.Error = null.StringFrom(err.Error())
+			return &s
+		}
+		s.GemText = null.StringFrom(validBody)
+	} else {
+		s.Data = null.ValueFrom([]byte(body))
+	}
+	return &s
+}
+
+// parseHeader splits a Spartan response
+// header into its status code and meta.
+func parseHeader(header string) (int, string, error) {
+	status, meta, _ := strings.Cut(header, " ")
+	code, err := strconv.Atoi(status)
+	if err != nil || code < StatusSuccess || code > StatusServerError {
+		return 0, "", xerrors.NewSimpleError(fmt.Errorf("invalid spartan header: %s", header))
+	}
+	return code, strings.TrimSpace(meta), nil
+}
+
+// parseMimeType returns the MIME type and
+// language of a success response meta.
+func parseMimeType(meta string) (string, string) {
+	parts := strings.Split(meta, ";")
+	mimeType := strings.ToLower(strings.TrimSpace(parts[0]))
+	if mimeType == "" {
+		mimeType = "text/gemini"
+	}
+	var lang string
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found && strings.EqualFold(key, "lang") {
+			lang = value
+		}
+	}
+	return mimeType, lang
+}
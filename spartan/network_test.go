@@ -0,0 +1,127 @@
+package spartan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	stdurl "net/url"
+	"testing"
+
+	"gemini-grc/common/snapshot"
+	"gemini-grc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"spartan://mozz.us", "mozz.us / 0\r\n"},
+		{"spartan://mozz.us:300/", "mozz.us / 0\r\n"},
+		{"spartan://mozz.us/notes/hello%20world.gmi", "mozz.us /notes/hello%20world.gmi 0\r\n"},
+		{"spartan://mozz.us/echo?hello%20there", "mozz.us /echo 11\r\nhello there"},
+	}
+
+	for _, tt := range tests {
+		u, err := stdurl.Parse(tt.url)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, string(buildRequest(u)))
+	}
+}
+
+func TestUpdateSnapshotWithData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		data     string
+		code     int64
+		mimeType string
+		lang     string
+		gemText  string
+		isError  bool
+	}{
+		{"Gemtext", "2 text/gemini\r\n# Hello\n", 2, "text/gemini", "", "# Hello\n", false},
+		{"Gemtext with lang", "2 text/gemini; lang=el\r\nΓεια\n", 2, "text/gemini", "el", "Γεια\n", false},
+		{"Plain text", "2 text/plain\r\nHello", 2, "text/plain", "", "", false},
+		{"Redirect", "3 /new/place\r\n", 3, "", "", "", false},
+		{"Client error", "4 Not found\r\n", 4, "", "", "", true},
+		{"Server error", "5 Oops\r\n", 5, "", "", "", true},
+		{"Gemini status code", "20 text/gemini\r\n", 0, "", "", "", true},
+		{"No header", "Hello", 0, "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := snapshot.SnapshotFromURL("spartan://mozz.us/", true)
+			assert.NoError(t, err)
+			s = UpdateSnapshotWithData(*s, []byte(tt.data))
+			assert.Equal(t, tt.code, s.ResponseCode.ValueOrZero())
+			assert.Equal(t, tt.mimeType, s.MimeType.ValueOrZero())
+			assert.Equal(t, tt.lang, s.Lang.ValueOrZero())
+			assert.Equal(t, tt.gemText, s.GemText.ValueOrZero())
+			assert.Equal(t, tt.isError, s.Error.Valid)
+		})
+	}
+}
+
+func TestVisit(t *testing.T) {
+	responses := map[string]string{
+		"/": "2 text/gemini\r\n" +
+			"# Welcome\n" +
+			"=> /about.gmi About\n" +
+			"=: /search Search\n" +
+			"=> gemini://example.com/ Elsewhere\n",
+		"/old": "3 /\r\n",
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var host, path string
+			var length int
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			_, _ = fmt.Sscanf(line, "%s %s %d", &host, &path, &length)
+			_, _ = conn.Write([]byte(responses[path]))
+			_ = conn.Close()
+		}
+	}()
+
+	originalConfig := config.CONFIG
+	defer func() {
+		config.CONFIG = originalConfig
+	}()
+	config.CONFIG.ResponseTimeout = 5
+	config.CONFIG.MaxResponseSize = 1024
+
+	address := listener.Addr().String()
+	ctx := context.Background()
+
+	s, err := Visit(ctx, "spartan://"+address+"/")
+	assert.NoError(t, err)
+	assert.False(t, s.Error.Valid)
+	assert.Equal(t, int64(StatusSuccess), s.ResponseCode.ValueOrZero())
+	links := Links(s)
+	if assert.Len(t, links, 2) {
+		assert.Equal(t, "spartan://"+address+"/about.gmi", links[0].Full)
+		assert.Equal(t, "gemini://example.com:1965/", links[1].Full)
+	}
+
+	s, err = Visit(ctx, "spartan://"+address+"/old")
+	assert.NoError(t, err)
+	assert.False(t, s.Error.Valid)
+	assert.Equal(t, int64(StatusRedirect), s.ResponseCode.ValueOrZero())
+	assert.Equal(t, "3 /", s.Header.ValueOrZero())
+}
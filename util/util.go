@@ -60,3 +60,25 @@ func Map[T any, R any](slice []T, f func(T) R) []R {
 	}
 	return result
 }
+
+// Diff returns the elements of after that aren't in before,
+// and the elements of before that aren't in after.
+func Diff[T comparable](before []T, after []T) (added []T, removed []T) {
+	inBefore := make(map[T]bool, len(before))
+	for _, v := range before {
+		inBefore[v] = true
+	}
+	inAfter := make(map[T]bool, len(after))
+	for _, v := range after {
+		inAfter[v] = true
+		if !inBefore[v] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range before {
+		if !inAfter[v] {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}